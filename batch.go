@@ -0,0 +1,123 @@
+package scheme
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrBatchLength = errors.New("scheme: batch lengths do not match")
+	ErrBatchVerify = errors.New("scheme: batch verification failed")
+)
+
+// VerifyBatch verifies many signatures at once. It checks a random linear
+// combination of the verification equations
+//
+//	sum(z_i * s_i) * G = sum(z_i * R_i) + sum(z_i * c_i * pub_i)
+//
+// with a single multi-scalar multiplication. pubs must have the same length as
+// msgs and sigs, or a single entry used for every signature.
+//
+// It returns nil if every signature is valid. Otherwise the signatures are
+// verified one by one and the indices of the invalid ones are returned along
+// with ErrBatchVerify.
+func VerifyBatch(msgs []string, sigs []*Signature, pubs []group.Element) ([]int, error) {
+	n := len(sigs)
+	if len(msgs) != n || (len(pubs) != n && len(pubs) != 1) {
+		return nil, fmt.Errorf("%w: %d messages, %d signatures, %d public keys", ErrBatchLength, len(msgs), n, len(pubs))
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	pubAt := func(i int) group.Element {
+		if len(pubs) == 1 {
+			return pubs[0]
+		}
+		return pubs[i]
+	}
+
+	if !verifyBatch(msgs, sigs, pubAt) {
+		failed := make([]int, 0)
+		for i, sig := range sigs {
+			if sig == nil || !sig.Verify(pubAt(i), msgs[i]) {
+				failed = append(failed, i)
+			}
+		}
+		// Signatures of different groups can not be combined, but may all be valid
+		if len(failed) != 0 {
+			return failed, ErrBatchVerify
+		}
+	}
+	return nil, nil
+}
+
+// verifyBatch reports whether the random linear combination of all
+// verification equations holds
+func verifyBatch(msgs []string, sigs []*Signature, pubAt func(int) group.Element) bool {
+	n := len(sigs)
+	if pubAt(0) == nil {
+		return false
+	}
+	g := groupOf(pubAt(0).Group())
+	scalars := make([]group.Scalar, 0, 2*n+1)
+	points := make([]group.Element, 0, 2*n+1)
+
+	// Coefficients of the same public key are merged
+	pubIdx := make(map[string]int)
+
+	sAgg := g.NewScalar()
+	scalars = append(scalars, sAgg)
+	points = append(points, g.Generator())
+
+	buf := make([]byte, 16)
+	for i, sig := range sigs {
+		pub := pubAt(i)
+		if sig == nil || sig.S == nil || !sameGroup(g, pub, sig.R) || groupOf(sig.S.Group()) != g {
+			return false
+		}
+		h, err := SuiteByID(sig.Suite)
+		if err != nil {
+			return false
+		}
+
+		// 128 bit random coefficient z_i, z_0 = 1
+		z := g.NewScalar()
+		if i == 0 {
+			z.SetUint64(1)
+		} else {
+			if _, err := rand.Read(buf); err != nil {
+				return false
+			}
+			z.SetBigInt(new(big.Int).SetBytes(buf))
+		}
+
+		// sAgg += z_i * s_i
+		zs := g.NewScalar()
+		zs.Mul(z, sig.S)
+		sAgg.Add(sAgg, zs)
+
+		// -z_i * c_i * pub_i
+		zc := g.NewScalar()
+		zc.Mul(z, challenge(h, NewMessage([]byte(msgs[i])), sig.R, pub))
+		zc.Neg(zc)
+		key := string(elementBytes(pub))
+		if j, ok := pubIdx[key]; ok {
+			scalars[j].Add(scalars[j], zc)
+		} else {
+			pubIdx[key] = len(scalars)
+			scalars = append(scalars, zc)
+			points = append(points, pub)
+		}
+
+		// -z_i * R_i
+		z.Neg(z)
+		scalars = append(scalars, z)
+		points = append(points, sig.R)
+	}
+
+	return multiScalarMult(g, scalars, points).IsIdentity()
+}
@@ -0,0 +1,59 @@
+package scheme_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func batch(n int) ([]string, []*scheme.Signature) {
+	msgs := make([]string, 0, n)
+	sigs := make([]*scheme.Signature, 0, n)
+	for i := 0; i < n; i++ {
+		m := fmt.Sprintf("telemetry record %d", i)
+		msgs = append(msgs, m)
+		sigs = append(sigs, thresholdSign(m))
+	}
+	return msgs, sigs
+}
+
+func TestVerifyBatch(t *testing.T) {
+	msgs, sigs := batch(8)
+	pubs := []group.Element{crt.Pub}
+
+	failed, err := scheme.VerifyBatch(msgs, sigs, pubs)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+
+	// Swap two messages and drop a signature
+	msgs[2], msgs[5] = msgs[5], msgs[2]
+	sigs[7] = nil
+	failed, err = scheme.VerifyBatch(msgs, sigs, pubs)
+	assert.ErrorIs(t, err, scheme.ErrBatchVerify)
+	assert.Equal(t, []int{2, 5, 7}, failed)
+
+	_, err = scheme.VerifyBatch(msgs[1:], sigs, pubs)
+	assert.ErrorIs(t, err, scheme.ErrBatchLength)
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	msgs, sigs := batch(64)
+	pubs := []group.Element{crt.Pub}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scheme.VerifyBatch(msgs, sigs, pubs)
+	}
+}
+
+func BenchmarkVerifyEach(b *testing.B) {
+	msgs, sigs := batch(64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, sig := range sigs {
+			sig.Verify(crt.Pub, msgs[j])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Package bigint selects the arbitrary precision integers of the scheme, its
+// API takes and returns them. Convert from and to math/big with FromBig and ToBig.
+// With cgo the integers are those of GMP, build with the purego tag or
+// CGO_ENABLED=0 to use math/big instead, for static and cross builds.
+// Both backends encode to the same gob and JSON.
+package bigint
+
+import "math/big"
+
+// ClearBig zeroes the words of the math/big integer x and sets it to 0, with
+// either backend
+func ClearBig(x *big.Int) {
+	words := x.Bits()
+	clear(words[:cap(words)])
+	x.SetInt64(0)
+}
+
+// FromBig returns a copy of the math/big integer x as an Int
+func FromBig(x *big.Int) *Int {
+	buf := x.Bytes()
+	defer clear(buf)
+	z := new(Int).SetBytes(buf)
+	if x.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+// ToBig returns a copy of x as a math/big integer
+func ToBig(x *Int) *big.Int {
+	buf := x.Bytes()
+	defer clear(buf)
+	z := new(big.Int).SetBytes(buf)
+	if x.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
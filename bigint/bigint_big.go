@@ -0,0 +1,21 @@
+//go:build !cgo || purego
+
+package bigint
+
+import "math/big"
+
+// Backend is the name of the selected implementation
+const Backend = "math/big"
+
+// Int is an arbitrary precision integer
+type Int = big.Int
+
+// NewInt allocates and returns a new Int set to x
+func NewInt(x int64) *Int {
+	return big.NewInt(x)
+}
+
+// Clear zeroes the words of x and sets it to 0
+func Clear(x *Int) {
+	ClearBig(x)
+}
@@ -0,0 +1,20 @@
+//go:build !cgo || purego
+
+package bigint_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearWords(t *testing.T) {
+	x := new(bigint.Int).Lsh(bigint.NewInt(7), 500)
+	words := x.Bits()
+	words = words[:cap(words)]
+	bigint.Clear(x)
+	for _, w := range words {
+		assert.Zero(t, w)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build cgo && !purego
+
+package bigint
+
+/*
+#cgo LDFLAGS: -lgmp
+#include <gmp.h>
+#include <string.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/ncw/gmp"
+)
+
+// Backend is the name of the selected implementation
+const Backend = "gmp"
+
+// Int is an arbitrary precision integer
+type Int = gmp.Int
+
+// NewInt allocates and returns a new Int set to x
+func NewInt(x int64) *Int {
+	return gmp.NewInt(x)
+}
+
+// Clear zeroes x and frees its memory
+func Clear(x *Int) {
+	wipe(x)
+	x.Clear()
+}
+
+// mpz returns the mpz_t of x, which is the first field of gmp.Int. Its
+// limbs are nil as long as x is not initialized.
+func mpz(x *Int) *C.__mpz_struct {
+	return (*C.__mpz_struct)(unsafe.Pointer(x))
+}
+
+// limbs returns all the allocated limbs of x, not only the used ones
+func limbs(x *Int) []C.mp_limb_t {
+	z := mpz(x)
+	if z._mp_d == nil || z._mp_alloc <= 0 {
+		return nil
+	}
+	return unsafe.Slice((*C.mp_limb_t)(unsafe.Pointer(z._mp_d)), int(z._mp_alloc))
+}
+
+// wipe zeroes the allocated limbs of x, mpz_clear frees them as they are
+func wipe(x *Int) {
+	if l := limbs(x); len(l) != 0 {
+		C.memset(unsafe.Pointer(&l[0]), 0, C.size_t(len(l))*C.size_t(unsafe.Sizeof(l[0])))
+	}
+}
@@ -0,0 +1,25 @@
+//go:build cgo && !purego
+
+package bigint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWipeLimbs(t *testing.T) {
+	assert.Nil(t, limbs(new(Int)))
+	wipe(new(Int))
+
+	x := new(Int).Lsh(NewInt(7), 500)
+	l := limbs(x)
+	assert.NotEmpty(t, l)
+	assert.NotZero(t, l[500/64])
+	wipe(x)
+	for _, w := range l {
+		assert.Zero(t, w)
+	}
+	Clear(x)
+	assert.Zero(t, x.BitLen())
+}
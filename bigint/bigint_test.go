@@ -0,0 +1,53 @@
+package bigint_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/big"
+	"testing"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClear(t *testing.T) {
+	x := new(bigint.Int).Lsh(bigint.NewInt(3), 300)
+	bigint.Clear(x)
+	assert.Equal(t, 0, x.Sign())
+	assert.Equal(t, 0, x.BitLen())
+}
+
+func TestClearBig(t *testing.T) {
+	x := new(big.Int).Lsh(big.NewInt(5), 400)
+	words := x.Bits()
+	words = words[:cap(words)]
+	bigint.ClearBig(x)
+	assert.Equal(t, 0, x.Sign())
+	for _, w := range words {
+		assert.Zero(t, w)
+	}
+}
+
+func TestBig(t *testing.T) {
+	for _, x := range []*big.Int{big.NewInt(0), new(big.Int).Lsh(big.NewInt(7), 300), new(big.Int).Lsh(big.NewInt(-3), 200)} {
+		y := bigint.FromBig(x)
+		assert.Equal(t, x.String(), y.String())
+		z := bigint.ToBig(y)
+		assert.Equal(t, 0, x.Cmp(z))
+		assert.NotSame(t, x, z)
+	}
+}
+
+func TestGob(t *testing.T) {
+	x := new(bigint.Int).Lsh(bigint.NewInt(-5), 200)
+	// Version 1 and the sign bit, then the big-endian magnitude, on both backends
+	data, err := x.GobEncode()
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{1<<1 | 1}, x.Bytes()...), data)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, gob.NewEncoder(buf).Encode(x))
+	y := new(bigint.Int)
+	assert.NoError(t, gob.NewDecoder(buf).Decode(y))
+	assert.Equal(t, 0, x.Cmp(y))
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,12 +14,14 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/52funny/scheme"
-	"github.com/cloudflare/circl/ecc/bls12381"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/ncw/gmp"
 )
 
 var upgrader = websocket.Upgrader{}
@@ -29,60 +33,137 @@ type Message struct {
 
 // UavPubMessage is the parameter that the drone gives to the aggregator
 type UavPubMessage struct {
-	ID string
-	E  []byte
-	D  []byte
-	P  *gmp.Int
+	ID   string
+	P    *bigint.Int
+	Cost float64 // Cost hint of the drone, 0 if unknown
+}
+
+// CommitRequestMessage opens the commit round of a signing session, the
+// drones of the signing set derive their nonces from the session, the message
+// and the moduli of the set
+type CommitRequestMessage struct {
+	Session string                // Session ID
+	Context scheme.SessionContext // Context the drones bind the signature to
+	Msg     string
+	Moduli  []*bigint.Int // Moduli of the signing set
+}
+
+// CommitMessage is the fresh nonce commitment (E, D) of a drone for a session
+type CommitMessage struct {
+	ID      string
+	Session string      // Session ID of the commit round
+	P       *bigint.Int // Modulus of the drone
+	Index   uint64      // Index of the nonce pair in the pool of the drone
+	E       []byte
+	D       []byte
 }
 
 // SignPrepMessage is the message that the aggregator sends to the drone
 type SignPrepMessage struct {
-	Msg string
-	B   B
+	Session string                // Session ID of the commitments in B
+	Context scheme.SessionContext // Context the drones bind the signature to
+	Msg     string
+	B       B
 }
 
 // SignResultMessage is the message that the drone sends to the aggregator
 // (s, R) schnorr signature
 type SignResultMessage struct {
-	S *gmp.Int
-	R []byte
+	Session string      // Session ID
+	P       *bigint.Int // Modulus of the drone
+	S       *bigint.Int
+	R       []byte
 }
 
 type BItem struct {
-	P *gmp.Int // The prime number
-	E []byte   // The E
-	D []byte   // The D
+	P     *bigint.Int // The prime number
+	E     []byte      // The E
+	D     []byte      // The D
+	Nonce uint64      // Index of the nonce pair
 }
 
 type B []BItem
 
-// Schnorr Signature
-type Signature struct {
-	S *gmp.Int
-	R *bls12381.G1
+// PartialSignature is the (s, R) pair sent by a single drone
+type PartialSignature struct {
+	Session string
+	P       *bigint.Int
+	S       *bigint.Int
+	R       group.Element
 }
 
 const TA_ADDR = "localhost:1234"
 
-// Public key
-var pub bls12381.G1
+// Connection to the TA
+var ta *rpc.Client
+
+// Public parameters of the sharing with the public key, without the share
+// commitments, and the refresh epoch of the shares
+var params *scheme.GroupParams
+var epoch uint64
+var paramsMux sync.Mutex
+
+// Revocation list signed by the TA and the key it is signed with
+var revocation *scheme.RevocationList
+var revocationKey group.Element
+var revocationMux sync.Mutex
+
+// ID of the current signing session, its signing set and context, sent to the drones with SIGNPREP
+var sessionID string
+var session scheme.B
+var sessionCtx *scheme.SessionContext
+var sessionMux sync.Mutex
+
+// Moduli of the signing set selected for the commit round of the session
+var sessionSigners []*bigint.Int
+
+// Message signed in the sessions
+const sessionText = "Hello World!"
+
+// Strategies to select the signing set
+var strategies = map[string]scheme.Strategy{
+	"fewest": scheme.FewestSigners,
+	"cost":   scheme.LeastCost,
+	"weight": scheme.HighWeight,
+}
+
+var strategy = flag.String("strategy", "fewest", "signing set selection: fewest, cost or weight")
+
+// ID of the aggregator in the session context of the signatures
+var aggregatorID = flag.String("id", "", "ID of the aggregator bound into the signatures, a random UUID if empty")
 
 var signTimeStart time.Time
 
 func main() {
-	// Connect to the TA so that we can get the public key
+	flag.Parse()
+	if _, ok := strategies[*strategy]; !ok {
+		log.Fatal("unknown strategy:", *strategy)
+	}
+	if *aggregatorID == "" {
+		*aggregatorID = uuid.New().String()
+	}
+
+	// Connect to the TA so that we can get the public parameters
 	client, err := rpc.Dial("tcp", TA_ADDR)
 	if err != nil {
 		log.Fatal("dialing:", err)
 	}
+	ta = client
 
-	var pubBytes []byte
-	err = client.Call("RpcService.GetPublicKey", 0, &pubBytes)
-	if err != nil {
-		log.Fatal("register error:", err)
+	if err := fetchParams(client); err != nil {
+		log.Fatal("group params error:", err)
+	}
+	fmt.Printf("pub: %x\n", compress(groupParams().Pub))
+	if err := fetchRevocationKey(client); err != nil {
+		log.Fatal("revocation key error:", err)
+	}
+	var rlBytes []byte
+	if err := client.Call("RpcService.GetRevocationList", 0, &rlBytes); err != nil {
+		log.Fatal("revocation list error:", err)
+	}
+	if err := setRevocationList(rlBytes); err != nil {
+		log.Fatal("revocation list error:", err)
 	}
-	pub.SetBytes(pubBytes)
-	fmt.Printf("pub: %x\n", pub.BytesCompressed())
 
 	upgrader.CheckOrigin = func(r *http.Request) bool {
 		return true
@@ -90,7 +171,7 @@ func main() {
 
 	store := NewStore()
 
-	collectSignCh := make(chan Signature, 256)
+	collectSignCh := make(chan PartialSignature, 256)
 	aggreCh := make(chan interface{})
 	go CollectSignature(collectSignCh, aggreCh, store)
 
@@ -111,11 +192,251 @@ func main() {
 			aggreCh <- struct{}{}
 			continue
 		}
+		if s == "refresh" {
+			// Refresh the shares at the TA, then let the drones fetch their offsets
+			var epoch int
+			if err := client.Call("RpcService.Refresh", 0, &epoch); err != nil {
+				log.Println("refresh error:", err)
+				continue
+			}
+			if err := fetchParams(client); err != nil {
+				log.Println("group params error:", err)
+				continue
+			}
+			fmt.Println("Refresh epoch:", epoch)
+		}
+		if s == "commit" {
+			// Every session starts with fresh nonce commitments of a signing
+			// set selected up front, the drones bind their nonces to it
+			id := uuid.New().String()
+			signers, err := selectSigners(store)
+			if err != nil {
+				log.Println("commit error:", err)
+				continue
+			}
+			store.NewRound(id, signers)
+			sessionMux.Lock()
+			sessionID, session, sessionCtx, sessionSigners = id, nil, newContext(id), signers
+			sessionMux.Unlock()
+			fmt.Println("Session:", id)
+		}
+		if s == "signprep" {
+			// Every drone of the signing set must have committed, every drone gets the same B
+			b, err := closeRound(store)
+			if err != nil {
+				log.Println("signprep error:", err)
+				continue
+			}
+			sessionMux.Lock()
+			session = b
+			sessionMux.Unlock()
+			fmt.Println("Signing set:", len(b), "of", store.Len(), "drones")
+		}
+		if fields := strings.Fields(s); len(fields) == 2 && fields[0] == "revoke" {
+			// Revoke the modulus at the TA and drop the drone
+			modulus, ok := new(bigint.Int).SetString(fields[1], 10)
+			if !ok {
+				log.Println("revoke: invalid modulus", fields[1])
+				continue
+			}
+			var rlBytes []byte
+			if err := client.Call("RpcService.Revoke", modulus, &rlBytes); err != nil {
+				log.Println("revoke error:", err)
+				continue
+			}
+			if err := setRevocationList(rlBytes); err != nil {
+				log.Println("revocation list error:", err)
+				continue
+			}
+			// The thresholds are recomputed without the revoked modulus
+			if err := fetchParams(client); err != nil {
+				log.Println("group params error:", err)
+				continue
+			}
+			if id := store.IDOf(modulus); id != "" {
+				store.Delete(id)
+			}
+			fmt.Println("Revoked modulus:", modulus)
+			continue
+		}
 		hub.broadcast <- s
 	}
 }
 
-func listen(hub *Hub, store *Store, collect chan Signature) http.HandlerFunc {
+// selectSigners selects a minimal signing set among the registered drones
+func selectSigners(store *Store) ([]*bigint.Int, error) {
+	// Any set with a product of at least PMin2 can sign
+	selected, err := groupParams().SelectSigners(store.Candidates(), strategies[*strategy])
+	if err != nil {
+		return nil, err
+	}
+	moduli := make([]*bigint.Int, 0, len(selected))
+	for _, c := range selected {
+		moduli = append(moduli, c.Modulus)
+	}
+	return moduli, nil
+}
+
+// closeRound closes the commit round and returns the commitments of the
+// signing set, all of its drones must have committed
+func closeRound(store *Store) (scheme.B, error) {
+	_, _, ctx := currentSession()
+	if ctx == nil {
+		return nil, errors.New("no open commit round")
+	}
+	b := store.B()
+	// The commitments are used by this session only
+	store.EndRound()
+	if signers := commitSigners(); len(b) != len(signers) {
+		return nil, fmt.Errorf("%d of %d drones of the signing set committed", len(b), len(signers))
+	}
+	return b, nil
+}
+
+// currentSession returns the ID, the signing set and the context of the current session
+func currentSession() (string, scheme.B, *scheme.SessionContext) {
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+	return sessionID, session, sessionCtx
+}
+
+// commitSigners returns the moduli of the signing set of the commit round
+func commitSigners() []*bigint.Int {
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+	return sessionSigners
+}
+
+// endSession closes the current session, the partial signatures of its drones are stale
+func endSession() {
+	sessionMux.Lock()
+	sessionID, session, sessionCtx, sessionSigners = "", nil, nil, nil
+	sessionMux.Unlock()
+}
+
+// newContext returns the context of the session in the current epoch
+func newContext(id string) *scheme.SessionContext {
+	paramsMux.Lock()
+	defer paramsMux.Unlock()
+	return &scheme.SessionContext{
+		SessionID:    []byte(id),
+		GroupID:      compress(params.Pub),
+		Epoch:        epoch,
+		AggregatorID: []byte(*aggregatorID),
+	}
+}
+
+// sessionMessage returns the message signed in the session
+func sessionMessage(ctx *scheme.SessionContext) scheme.Message {
+	return scheme.NewMessage([]byte(sessionText)).WithContext(ctx)
+}
+
+// fetchParams fetches the public parameters and the refresh epoch from the
+// TA, they change with every refresh and revocation
+func fetchParams(client *rpc.Client) error {
+	var data []byte
+	if err := client.Call("RpcService.GetGroupParams", 0, &data); err != nil {
+		return err
+	}
+	var e int
+	if err := client.Call("RpcService.GetEpoch", 0, &e); err != nil {
+		return err
+	}
+	p := new(scheme.GroupParams)
+	if err := p.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	paramsMux.Lock()
+	params, epoch = p, uint64(e)
+	paramsMux.Unlock()
+	return nil
+}
+
+// groupParams returns the current public parameters
+func groupParams() *scheme.GroupParams {
+	paramsMux.Lock()
+	defer paramsMux.Unlock()
+	return params
+}
+
+// fetchRevocationKey fetches the key the TA signs the revocation lists with
+func fetchRevocationKey(client *rpc.Client) error {
+	var data []byte
+	if err := client.Call("RpcService.GetRevocationKey", 0, &data); err != nil {
+		return err
+	}
+	key := groupParams().Group.NewElement()
+	if err := key.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	revocationMux.Lock()
+	revocationKey = key
+	revocationMux.Unlock()
+	return nil
+}
+
+// setRevocationList replaces the revocation list after checking the signature
+// of the TA, a list older than the current one is rejected
+func setRevocationList(data []byte) error {
+	rl := new(scheme.RevocationList)
+	if err := rl.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	revocationMux.Lock()
+	defer revocationMux.Unlock()
+	var version uint64
+	if revocation != nil {
+		version = revocation.Version
+	}
+	if !rl.Verify(revocationKey, version) {
+		return scheme.ErrRevocationList
+	}
+	revocation = rl
+	return nil
+}
+
+// isRevoked reports whether the modulus p is on the revocation list
+func isRevoked(p *bigint.Int) bool {
+	revocationMux.Lock()
+	defer revocationMux.Unlock()
+	return revocation != nil && revocation.Contains(p)
+}
+
+// known reports whether the modulus p is part of the group parameters
+func known(p *bigint.Int) bool {
+	return slices.ContainsFunc(groupParams().Moduli, func(m *bigint.Int) bool { return m.Cmp(p) == 0 })
+}
+
+// blame verifies the partial signatures of the drones of B against the
+// signing commitments of the TA and returns the indices of the invalid ones
+func blame(msg scheme.Message, signs []*bigint.Int, R []group.Element, pub group.Element, B scheme.B) ([]int, error) {
+	moduli := make([]*bigint.Int, 0, len(B))
+	for _, item := range B {
+		moduli = append(moduli, item.P)
+	}
+	var data [][]byte
+	if err := ta.Call("RpcService.GetSigningCommitments", moduli, &data); err != nil {
+		return nil, err
+	}
+	U := make([]group.Element, 0, len(data))
+	for _, d := range data {
+		u := groupParams().Group.NewElement()
+		if err := u.UnmarshalBinary(d); err != nil {
+			return nil, err
+		}
+		U = append(U, u)
+	}
+	failed, err := scheme.VerifyPartialsMessage(scheme.DefaultSuite, msg, signs, R, U, pub, B)
+	if err != nil && len(failed) == 0 {
+		return nil, err
+	}
+	return failed, nil
+}
+
+func listen(hub *Hub, store *Store, collect chan PartialSignature) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -136,17 +457,23 @@ func listen(hub *Hub, store *Store, collect chan Signature) http.HandlerFunc {
 }
 
 type Client struct {
-	store   *Store          // Store
-	conn    *websocket.Conn // WebSocket connection
-	send    chan string     // Send channel
-	collect chan Signature  // Collect Signature
-	hub     *Hub            // Hub
+	store   *Store                // Store
+	id      string                // ID of the drone, empty until it sent its parameters
+	p       *bigint.Int           // Modulus of the drone, nil until it sent its parameters
+	conn    *websocket.Conn       // WebSocket connection
+	send    chan string           // Send channel
+	collect chan PartialSignature // Collect Signature
+	hub     *Hub                  // Hub
 }
 
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		// The modulus is free for the next connection of the drone
+		if c.p != nil {
+			c.store.Delete(c.id)
+		}
 	}()
 	for {
 		mt, message, err := c.conn.ReadMessage()
@@ -173,14 +500,42 @@ func (c *Client) writePump() {
 	defer c.conn.Close()
 	for cmd := range c.send {
 		switch strings.ToLower(cmd) {
+		case "commit":
+			id, _, ctx := currentSession()
+			if ctx == nil {
+				continue
+			}
+			req := CommitRequestMessage{
+				Session: id,
+				Context: *ctx,
+				Msg:     sessionText,
+				Moduli:  commitSigners(),
+			}
+			buffer := new(bytes.Buffer)
+			if err := gob.NewEncoder(buffer).Encode(&req); err != nil {
+				log.Println("encode:", err)
+				continue
+			}
+			msg := Message{
+				Type: "COMMIT_REQ",
+				Data: buffer.Bytes(),
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+			fmt.Println("Commit Request is sent")
 		case "signprep":
-			c.store.mux.Lock()
-			b := transform(c.store.m)
-			c.store.mux.Unlock()
+			id, B, ctx := currentSession()
+			b := transform(B)
+			if len(b) == 0 || ctx == nil {
+				continue
+			}
 
 			data := SignPrepMessage{
-				Msg: "Hello World!",
-				B:   b,
+				Session: id,
+				Context: *ctx,
+				Msg:     sessionText,
+				B:       b,
 			}
 			buffer := new(bytes.Buffer)
 			e := gob.NewEncoder(buffer).Encode(&data)
@@ -197,6 +552,16 @@ func (c *Client) writePump() {
 				return
 			}
 			fmt.Println("SignPrep Command is sent")
+		case "refresh":
+			msg := Message{
+				Type: "REFRESH",
+				Data: nil,
+			}
+			err := c.conn.WriteJSON(msg)
+			if err != nil {
+				return
+			}
+			fmt.Println("Refresh Command is sent")
 		case "sign":
 			msg := Message{
 				Type: "SIGN",
@@ -214,19 +579,17 @@ func (c *Client) writePump() {
 	}
 }
 
-func transform(m map[string]*scheme.BItem) B {
+func transform(origin scheme.B) B {
 	var b B
-	for _, v := range m {
+	for _, v := range origin {
 		item := BItem{
-			P: v.P,
-			E: v.E.BytesCompressed(),
-			D: v.D.BytesCompressed(),
+			P:     v.P,
+			E:     compress(v.E),
+			D:     compress(v.D),
+			Nonce: v.Nonce,
 		}
 		b = append(b, item)
 	}
-	slices.SortFunc(b, func(a, b BItem) int {
-		return a.P.Cmp(b.P)
-	})
 	return b
 }
 
@@ -235,16 +598,61 @@ func (c *Client) handleMessage(msg *Message) {
 	case "PARAMS":
 		pp := UavPubMessage{}
 		gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&pp)
-		D := new(bls12381.G1)
-		D.SetBytes(pp.D)
-		E := new(bls12381.G1)
-		E.SetBytes(pp.E)
+		if pp.P == nil {
+			log.Println("decode: missing modulus from", pp.ID)
+			return
+		}
+		if c.p != nil {
+			log.Println("params: connection of", c.id, "is already registered")
+			return
+		}
+		if isRevoked(pp.P) {
+			log.Println("params: revoked drone", pp.ID)
+			return
+		}
+		// Drones enrolled after the start change the parameters
+		if !known(pp.P) {
+			if err := fetchParams(ta); err != nil {
+				log.Println("group params error:", err)
+			}
+		}
+		// The connection speaks for this modulus only, another
+		// connection may not take it over
+		if !c.store.Add(pp.ID, pp.P, pp.Cost) {
+			log.Println("params: drone", pp.ID, "or modulus", pp.P, "is already registered")
+			return
+		}
+		c.id, c.p = pp.ID, pp.P
+	case "COMMIT":
+		cm := CommitMessage{}
+		if err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&cm); err != nil {
+			log.Println("decode:", err)
+			return
+		}
+		g := groupParams().Group
+		D := g.NewElement()
+		E := g.NewElement()
+		if cm.P == nil || D.UnmarshalBinary(cm.D) != nil || E.UnmarshalBinary(cm.E) != nil {
+			log.Println("decode: invalid commitments from", cm.ID)
+			return
+		}
+		if c.p == nil || cm.P.Cmp(c.p) != 0 {
+			log.Println("commit: modulus", cm.P, "is not the one of the connection")
+			return
+		}
+		if isRevoked(cm.P) {
+			log.Println("commit: revoked drone", cm.ID)
+			return
+		}
 		bItem := scheme.BItem{
-			E: E,
-			D: D,
-			P: pp.P,
+			E:     E,
+			D:     D,
+			P:     cm.P,
+			Nonce: cm.Index,
+		}
+		if !c.store.Commit(cm.Session, c.id, &bItem) {
+			log.Println("commit: stale session", cm.Session, "from", c.id)
 		}
-		c.store.Add(pp.ID, &bItem)
 	case "SIGNRES":
 		signMsg := SignResultMessage{}
 		err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&signMsg)
@@ -252,46 +660,139 @@ func (c *Client) handleMessage(msg *Message) {
 			log.Println("decode:", err)
 			return
 		}
-		R := new(bls12381.G1)
-		R.SetBytes(signMsg.R)
-		sig := Signature{
-			S: signMsg.S,
-			R: R,
+		R := groupParams().Group.NewElement()
+		if err := R.UnmarshalBinary(signMsg.R); err != nil {
+			log.Println("decode:", err)
+			return
+		}
+		if signMsg.P == nil || signMsg.S == nil {
+			log.Println("decode: partial signature without modulus")
+			return
+		}
+		// A drone only signs for its own modulus, the partial is kept under
+		// the modulus of the connection
+		if c.p == nil || signMsg.P.Cmp(c.p) != 0 {
+			log.Println("sign: modulus", signMsg.P, "is not the one of the connection")
+			return
+		}
+		if isRevoked(signMsg.P) {
+			log.Println("sign: partial signature of revoked modulus", signMsg.P)
+			return
+		}
+		sig := PartialSignature{
+			Session: signMsg.Session,
+			P:       c.p,
+			S:       signMsg.S,
+			R:       R,
 		}
 		c.collect <- sig
 	}
 }
 
 // Collect Signature
-func CollectSignature(collectCh chan Signature, aggregate chan interface{}, store *Store) {
-	signs := make([]*gmp.Int, 0, 256)
-	R := make([]*bls12381.G1, 0, 256)
+func CollectSignature(collectCh chan PartialSignature, aggregate chan interface{}, store *Store) {
+	// Partial signatures of the current session by modulus
+	partials := make(map[string]PartialSignature, 256)
+	partialsSession := ""
 	for {
 		select {
 		case s := <-collectCh:
-			signs = append(signs, s.S)
-			R = append(R, s.R)
+			id, B, _ := currentSession()
+			if s.Session != id {
+				log.Println("Partial signature of a stale session:", s.Session)
+				continue
+			}
+			if partialsSession != id {
+				partials = make(map[string]PartialSignature, 256)
+				partialsSession = id
+			}
+			if !slices.ContainsFunc(B, func(item scheme.BItem) bool { return item.P.Cmp(s.P) == 0 }) {
+				log.Println("Partial signature outside the signing set:", s.P)
+				continue
+			}
+			if _, ok := partials[s.P.String()]; ok {
+				log.Println("Second partial signature of", s.P)
+				continue
+			}
+			partials[s.P.String()] = s
 
-			if store.Len() == len(signs) {
+			if len(B) == len(partials) {
 				fmt.Println("Sign Time Cost:", time.Since(signTimeStart))
 			}
 
 		case <-aggregate:
-			if len(R) == 0 {
+			id, B, ctx := currentSession()
+			if len(partials) == 0 || partialsSession != id {
 				log.Println("No signature to aggregate")
 				continue
 			}
-			p := store.CalculateP()
+			signs := make([]*bigint.Int, 0, len(B))
+			R := make([]group.Element, 0, len(B))
+			missing := false
+			for _, item := range B {
+				ps, ok := partials[item.P.String()]
+				if !ok {
+					log.Println("Missing partial signature of", store.IDOf(item.P))
+					missing = true
+					continue
+				}
+				signs = append(signs, ps.S)
+				R = append(R, ps.R)
+			}
+			if missing {
+				continue
+			}
+			p := new(bigint.Int).SetInt64(1)
+			for _, item := range B {
+				p.Mul(p, item.P)
+			}
 
+			pub := groupParams().Pub
+			msg := sessionMessage(ctx)
 			tt := time.Now()
-			z, r := scheme.Aggregate(signs, R[0], p)
+			sig, err := scheme.AggregateSignature(scheme.DefaultSuite, msg, signs, R[0], pub, p)
 			fmt.Println("Aggregate Time Cost:", time.Since(tt))
+			if err != nil {
+				// The session fails, its partial signatures are never used again
+				endSession()
+				partials = make(map[string]PartialSignature, 256)
+				partialsSession = ""
+
+				// Blame and exclude the drones with invalid partial signatures
+				failed, err := blame(msg, signs, R, pub, B)
+				if err != nil {
+					// Without the signing commitments of the dealer the faulty
+					// drone is unknown, none of the signing set signs again
+					log.Println("can not identify the faulty drones:", err)
+					failed = make([]int, 0, len(B))
+					for i := range B {
+						failed = append(failed, i)
+					}
+				}
+				if len(failed) == 0 {
+					log.Println("all partial signatures are valid, the signing set can not sign")
+					continue
+				}
+				for _, i := range failed {
+					log.Println("Excluded", store.IDOf(B[i].P), "modulus:", B[i].P)
+					store.Exclude(B[i].P)
+				}
+				log.Println("session failed, run commit, signprep and sign again")
+				continue
+			}
 
 			fmt.Println("Aggregated Signature:")
-			fmt.Printf("z: %v\n", z)
-			fmt.Printf("R: %x\n", r.BytesCompressed())
-			t := scheme.Verify("Hello World!", z, r, &pub)
+			fmt.Printf("z: %v\n", sig.S)
+			fmt.Printf("R: %x\n", compress(sig.R))
+			fmt.Printf("Signature: %s\n", sig)
+			t := sig.VerifyMessage(pub, msg)
 			fmt.Println("Verify:", t)
 		}
 	}
 }
+
+// compress returns the compressed encoding of the element
+func compress(e group.Element) []byte {
+	b, _ := e.MarshalBinaryCompress()
+	return b
+}
@@ -1,34 +1,92 @@
 package main
 
 import (
+	"slices"
 	"sync"
 
 	"github.com/52funny/scheme"
-	"github.com/ncw/gmp"
+	"github.com/52funny/scheme/bigint"
 )
 
 // Store is a store for BItem
 type Store struct {
-	m   map[string]*scheme.BItem
-	mux sync.Mutex
+	m       map[string]*bigint.Int   // Moduli of the drones
+	commits map[string]*scheme.BItem // Nonce commitments of the current commit round
+	round   string                   // Session ID of the current commit round, empty if closed
+	signers map[string]bool          // Moduli of the signing set of the current commit round
+	cost    map[string]float64       // Cost hints of the drones
+	exclude map[string]bool          // Moduli of the drones that may not sign again
+	mux     sync.Mutex
 }
 
 func NewStore() *Store {
 	return &Store{
-		m:   make(map[string]*scheme.BItem),
-		mux: sync.Mutex{},
+		m:       make(map[string]*bigint.Int),
+		commits: make(map[string]*scheme.BItem),
+		cost:    make(map[string]float64),
+		exclude: make(map[string]bool),
+		mux:     sync.Mutex{},
 	}
 }
 
-func (s *Store) Add(id string, b *scheme.BItem) {
+// Add registers the drone with its modulus, it reports false if the drone
+// or the modulus is registered already or the modulus is excluded
+func (s *Store) Add(id string, p *bigint.Int, cost float64) bool {
 	s.mux.Lock()
-	s.m[id] = b
+	defer s.mux.Unlock()
+	if _, ok := s.m[id]; ok || s.exclude[p.String()] {
+		return false
+	}
+	for _, m := range s.m {
+		if m.Cmp(p) == 0 {
+			return false
+		}
+	}
+	s.m[id] = p
+	s.cost[id] = cost
+	return true
+}
+
+// NewRound opens the commit round of the session for the drones with the
+// moduli and drops the commitments of the last one
+func (s *Store) NewRound(session string, moduli []*bigint.Int) {
+	s.mux.Lock()
+	s.round = session
+	s.commits = make(map[string]*scheme.BItem)
+	s.signers = make(map[string]bool, len(moduli))
+	for _, m := range moduli {
+		s.signers[m.String()] = true
+	}
 	s.mux.Unlock()
 }
 
+// EndRound closes the commit round, its commitments are never used again
+func (s *Store) EndRound() {
+	s.mux.Lock()
+	s.round = ""
+	s.commits = make(map[string]*scheme.BItem)
+	s.signers = nil
+	s.mux.Unlock()
+}
+
+// Commit adds the nonce commitment of a registered drone to the open round of
+// the session, it reports false for unknown drones, drones outside the
+// signing set and stale sessions
+func (s *Store) Commit(session, id string, b *scheme.BItem) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	p, ok := s.m[id]
+	if !ok || s.round == "" || session != s.round || p.Cmp(b.P) != 0 || !s.signers[p.String()] {
+		return false
+	}
+	s.commits[id] = b
+	return true
+}
+
+// Get returns the nonce commitment of the drone in the current round, nil if there is none
 func (s *Store) Get(id string) *scheme.BItem {
 	s.mux.Lock()
-	b := s.m[id]
+	b := s.commits[id]
 	s.mux.Unlock()
 	return b
 }
@@ -36,20 +94,75 @@ func (s *Store) Get(id string) *scheme.BItem {
 func (s *Store) Delete(id string) {
 	s.mux.Lock()
 	delete(s.m, id)
+	delete(s.commits, id)
+	delete(s.cost, id)
 	s.mux.Unlock()
 }
 
+// Exclude drops the drone with the modulus p, the modulus can not register again
+func (s *Store) Exclude(p *bigint.Int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.exclude[p.String()] = true
+	for id, v := range s.m {
+		if v.Cmp(p) == 0 {
+			delete(s.m, id)
+			delete(s.commits, id)
+			delete(s.cost, id)
+		}
+	}
+}
+
 func (s *Store) Len() int {
+	s.mux.Lock()
 	l := len(s.m)
+	s.mux.Unlock()
 	return l
 }
 
-func (s *Store) CalculateP() *gmp.Int {
+func (s *Store) CalculateP() *bigint.Int {
 	s.mux.Lock()
-	product := new(gmp.Int).SetInt64(1)
-	for _, v := range s.m {
-		product.Mul(product, v.P)
+	product := new(bigint.Int).SetInt64(1)
+	for _, p := range s.m {
+		product.Mul(product, p)
 	}
 	s.mux.Unlock()
 	return product
 }
+
+// B returns the nonce commitments of the current round sorted by modulus
+func (s *Store) B() scheme.B {
+	s.mux.Lock()
+	b := make(scheme.B, 0, len(s.commits))
+	for _, v := range s.commits {
+		b = append(b, *v)
+	}
+	s.mux.Unlock()
+	slices.SortFunc(b, func(x, y scheme.BItem) int {
+		return x.P.Cmp(y.P)
+	})
+	return b
+}
+
+// IDOf returns the id of the drone with the modulus p
+func (s *Store) IDOf(p *bigint.Int) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for id, v := range s.m {
+		if v.Cmp(p) == 0 {
+			return id
+		}
+	}
+	return ""
+}
+
+// Candidates returns the moduli and cost hints of the registered drones
+func (s *Store) Candidates() []scheme.Candidate {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := make([]scheme.Candidate, 0, len(s.m))
+	for id, p := range s.m {
+		c = append(c, scheme.Candidate{Modulus: p, Cost: s.cost[id]})
+	}
+	return c
+}
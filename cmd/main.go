@@ -1,16 +1,19 @@
 package main
 
 import (
+	"crypto/rand"
 	"fmt"
 	"time"
 
 	"github.com/52funny/scheme"
-	"github.com/cloudflare/circl/ecc/bls12381"
-	"github.com/ncw/gmp"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
 )
 
 func main() {
-	weight_opts := []int{64, 128, 256}
+	// A partial signature reveals u_i * G with u_i < P, the remainder of a
+	// modulus below 256 bits is found from it by a search
+	weight_opts := []int{256, 384, 512}
 	n := 100
 
 	moduli := scheme.GenerateNumber(weight_opts, n)
@@ -19,23 +22,20 @@ func main() {
 	fmt.Println(crt.ThresholdT1, crt.ThresholdT2)
 	fmt.Println(crt.PMin1.BitLen(), crt.PMin2.BitLen())
 
-	ei := make([]*bls12381.Scalar, 0, n)
-	Ei := make([]*bls12381.G1, 0, n)
-	di := make([]*bls12381.Scalar, 0, n)
-	Di := make([]*bls12381.G1, 0, n)
+	g := crt.Group
+	ei := make([]group.Scalar, 0, n)
+	Ei := make([]group.Element, 0, n)
+	di := make([]group.Scalar, 0, n)
+	Di := make([]group.Element, 0, n)
 	for i := 0; i < n; i++ {
-		ei = append(ei, scheme.GenerateScalar())
-		di = append(di, scheme.GenerateScalar())
+		ei = append(ei, g.RandomScalar(rand.Reader))
+		di = append(di, g.RandomScalar(rand.Reader))
 
 		// E = ei * G
-		E := new(bls12381.G1)
-		E.ScalarMult(ei[i], bls12381.G1Generator())
-		Ei = append(Ei, E)
+		Ei = append(Ei, g.NewElement().MulGen(ei[i]))
 
 		// D = di * G
-		D := new(bls12381.G1)
-		D.ScalarMult(di[i], bls12381.G1Generator())
-		Di = append(Di, D)
+		Di = append(Di, g.NewElement().MulGen(di[i]))
 	}
 
 	T := crt.ThresholdT2
@@ -43,7 +43,7 @@ func main() {
 	remainder := crt.Remainder
 	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
 
-	P := new(gmp.Int).SetInt64(1)
+	P := new(bigint.Int).SetInt64(1)
 
 	signers := make([]*scheme.Signer, 0, T)
 	for i := 0; i < T; i++ {
@@ -56,12 +56,15 @@ func main() {
 
 	m := "Hello"
 
-	signs := make([]*gmp.Int, 0, T)
-	R := new(bls12381.G1)
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
 	tt := time.Now()
 	for _, p := range signers {
 		tt := time.Now()
-		s, r := p.Sign(m, crt.Pub, B)
+		s, r, err := p.Sign(m, crt.Pub, B)
+		if err != nil {
+			panic(err)
+		}
 		fmt.Println("Every Sign Time Cost:", time.Since(tt))
 		R = r
 		signs = append(signs, s)
@@ -69,12 +72,17 @@ func main() {
 	fmt.Println("Sign Time Cost:", time.Since(tt))
 
 	tt = time.Now()
-	s, R := scheme.Aggregate(signs, R, P)
+	sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
+	if err != nil {
+		panic(err)
+	}
 	fmt.Println("Aggregate Time Cost:", time.Since(tt))
-	fmt.Printf("%-10s = %s\n%-10s = %x\n", "s", s, "R", R.Bytes())
+	Rb, _ := sig.R.MarshalBinary()
+	fmt.Printf("%-10s = %s\n%-10s = %x\n", "s", sig.S, "R", Rb)
+	fmt.Printf("%-10s = %s\n", "Signature", sig)
 
 	tt = time.Now()
-	res := scheme.Verify(m, s, R, crt.Pub)
+	res := sig.Verify(crt.Pub, m)
 	fmt.Println("Verify Time Cost:", time.Since(tt))
 	fmt.Println("Verify:", res)
 }
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	errNoDealer  = errors.New("the key was generated by the drones, the TA holds no secret")
+	errNoSharing = errors.New("the key generation has not finished")
+	errWiped     = errors.New("every share is handed out, the dealer is wiped")
+	errWaiting   = errors.New("waiting for the other drones")
+)
+
+// dkgState coordinates a dealerless key generation, the TA only relays the
+// keys, dealings and encrypted shares and never learns a remainder. The drones
+// sign their keys with identities pinned at the other drones, so the TA can
+// not replace the keys with its own.
+type dkgState struct {
+	dkg        *scheme.DKG
+	ids        map[string]int  // Index of the modulus of every joined drone
+	identities [][]byte        // Ed25519 identities in the order of the moduli
+	keys       [][]byte        // X25519 public keys in the order of the moduli, nil until bound
+	sigs       [][]byte        // Signatures of the keys by the identities
+	digests    [][]byte        // Digests the drones committed their dealings to
+	dealings   [][]byte        // Encoded public dealings in the order of the moduli
+	shares     [][][]byte      // shares[j][i] is the share of dealer j encrypted for drone i
+	Y          []group.Element // Share commitments in the order of the moduli
+	failed     error           // Set once a drone complains about a dealing
+}
+
+// DKGJoinArgs are the arguments of a drone joining the key generation
+type DKGJoinArgs struct {
+	ID       string // UUID V4
+	Identity []byte // Ed25519 identity the other drones pinned
+}
+
+// DKGBindArgs is the X25519 key of a drone signed by its identity
+type DKGBindArgs struct {
+	ID        string // UUID V4
+	Key       []byte // X25519 public key to encrypt the shares to the drone
+	Signature []byte // Signature of the key and the modulus by the identity
+}
+
+// DKGKeys are the identities and the signed keys of all the drones
+type DKGKeys struct {
+	Identities [][]byte // Ed25519 identities in the order of the moduli
+	Keys       [][]byte // X25519 public keys in the order of the moduli
+	Signatures [][]byte // Signatures of the keys by the identities
+}
+
+// DKGParams are the public parameters of the key generation
+type DKGParams struct {
+	Modulus   *bigint.Int    // Modulus of the drone
+	Moduli    []*bigint.Int  // Moduli of all the drones
+	Threshold int            // The threshold t
+	Group     scheme.GroupID // Group of the public key
+}
+
+// DKGCommitArgs is the digest of the dealing of a drone, sent before any
+// dealing is revealed
+type DKGCommitArgs struct {
+	ID     string // UUID V4
+	Digest []byte // Digest of the public part of the dealing
+}
+
+// DKGDealArgs is the dealing of a drone
+type DKGDealArgs struct {
+	ID      string   // UUID V4
+	Dealing []byte   // Public part of the dealing
+	Shares  [][]byte // Encrypted shares in the order of the moduli
+}
+
+// DKGShares are the dealings of all the drones and the shares encrypted for one of them
+type DKGShares struct {
+	Dealings [][]byte // Public parts of the dealings
+	Shares   [][]byte // Shares encrypted for the drone, one per dealer
+}
+
+// DKGFinishArgs is the share commitment of a drone, or its complaints
+type DKGFinishArgs struct {
+	ID         string // UUID V4
+	Y          []byte // Share commitment r * G
+	Complaints []int  // Indices of the dealers whose share did not verify
+}
+
+func NewDKGService(dkg *scheme.DKG) *RpcService {
+	return &RpcService{
+		dkg: &dkgState{
+			dkg:        dkg,
+			ids:        make(map[string]int),
+			identities: make([][]byte, 0, dkg.N),
+			keys:       make([][]byte, dkg.N),
+			sigs:       make([][]byte, dkg.N),
+			digests:    make([][]byte, dkg.N),
+			dealings:   make([][]byte, dkg.N),
+			shares:     make([][][]byte, dkg.N),
+			Y:          make([]group.Element, dkg.N),
+		},
+		ids:      make(map[string]*bigint.Int),
+		assigned: make(map[string]bool),
+		pending:  make(map[string]*bigint.Int),
+		rk:       scheme.NewRevocationKey(dkg.Group),
+	}
+}
+
+// DKGJoin assigns the next modulus to the drone
+func (r *RpcService) DKGJoin(args DKGJoinArgs, reply *DKGParams) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	if _, ok := s.ids[args.ID]; ok {
+		return fmt.Errorf("drone %s has already joined", args.ID)
+	}
+	if len(args.Identity) != ed25519.PublicKeySize {
+		return fmt.Errorf("drone %s has no valid identity", args.ID)
+	}
+	for _, identity := range s.identities {
+		if bytes.Equal(identity, args.Identity) {
+			return fmt.Errorf("identity of drone %s has already joined", args.ID)
+		}
+	}
+	if len(s.identities) == s.dkg.N {
+		return fmt.Errorf("The number of participants has reached the upper limit")
+	}
+	i := len(s.identities)
+	s.ids[args.ID] = i
+	s.identities = append(s.identities, args.Identity)
+	r.ids[args.ID] = s.dkg.Moduli[i]
+	r.assigned[s.dkg.Moduli[i].String()] = true
+	*reply = DKGParams{
+		Modulus:   s.dkg.Moduli[i],
+		Moduli:    s.dkg.Moduli,
+		Threshold: s.dkg.Thresholdt,
+		Group:     s.dkg.Group.ID(),
+	}
+	fmt.Println("DKG join id:", args.ID, " modulus:", reply.Modulus)
+	return nil
+}
+
+// DKGBind stores the key of the drone signed by its identity
+func (r *RpcService) DKGBind(args DKGBindArgs, reply *bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	i, ok := s.ids[args.ID]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", args.ID)
+	}
+	if s.keys[i] != nil {
+		return fmt.Errorf("drone %s has already sent its key", args.ID)
+	}
+	key, err := ecdh.X25519().NewPublicKey(args.Key)
+	if err != nil {
+		return err
+	}
+	if err := scheme.VerifyDKGKey(s.identities[i], key, s.dkg.Moduli[i], args.Signature); err != nil {
+		return err
+	}
+	s.keys[i], s.sigs[i] = args.Key, args.Signature
+	*reply = true
+	return nil
+}
+
+// DKGKeys returns the identities and the signed keys of all the drones once
+// every drone has sent its key
+func (r *RpcService) DKGKeys(args int, reply *DKGKeys) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	if len(s.identities) < s.dkg.N || slices.ContainsFunc(s.keys, func(k []byte) bool { return k == nil }) {
+		return errWaiting
+	}
+	*reply = DKGKeys{Identities: s.identities, Keys: s.keys, Signatures: s.sigs}
+	return nil
+}
+
+// DKGCommit stores the digest of the dealing of the drone, once per drone
+func (r *RpcService) DKGCommit(args DKGCommitArgs, reply *bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	j, ok := s.ids[args.ID]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", args.ID)
+	}
+	if s.digests[j] != nil {
+		return fmt.Errorf("drone %s has already committed to its dealing", args.ID)
+	}
+	if len(args.Digest) != sha256.Size {
+		return fmt.Errorf("digest of drone %s has %d bytes", args.ID, len(args.Digest))
+	}
+	s.digests[j] = args.Digest
+	*reply = true
+	return nil
+}
+
+// DKGCommits returns the digests of all the dealings once every drone has committed
+func (r *RpcService) DKGCommits(args int, reply *[][]byte) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	if !s.committed() {
+		return errWaiting
+	}
+	*reply = s.digests
+	return nil
+}
+
+// DKGDeal stores the dealing of the drone. It is only accepted once every
+// drone has committed to its dealing, once per drone and if it matches the
+// digest, so no drone chooses its dealing after seeing the others.
+func (r *RpcService) DKGDeal(args DKGDealArgs, reply *bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	j, ok := s.ids[args.ID]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", args.ID)
+	}
+	if !s.committed() {
+		return errWaiting
+	}
+	if s.dealings[j] != nil {
+		return fmt.Errorf("drone %s has already dealt", args.ID)
+	}
+	if len(args.Shares) != s.dkg.N {
+		return fmt.Errorf("dealing of drone %s has %d shares", args.ID, len(args.Shares))
+	}
+	dealing := new(scheme.DKGDealing)
+	if err := dealing.UnmarshalBinary(args.Dealing); err != nil {
+		return err
+	}
+	if err := s.dkg.CheckDealing(dealing); err != nil {
+		return err
+	}
+	digest, err := dealing.Digest()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, s.digests[j]) {
+		return fmt.Errorf("dealing of drone %s does not match its commitment", args.ID)
+	}
+	s.dealings[j] = args.Dealing
+	s.shares[j] = args.Shares
+	*reply = true
+	return nil
+}
+
+// DKGShares returns the dealings and the shares encrypted for the drone once every drone has dealt
+func (r *RpcService) DKGShares(id string, reply *DKGShares) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	i, ok := s.ids[id]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", id)
+	}
+	res := DKGShares{Dealings: s.dealings, Shares: make([][]byte, 0, s.dkg.N)}
+	for j := range s.shares {
+		if s.shares[j] == nil {
+			return errWaiting
+		}
+		res.Shares = append(res.Shares, s.shares[j][i])
+	}
+	*reply = res
+	return nil
+}
+
+// DKGFinish checks the share commitment of the drone, once all of them
+// are in the public key and the commitments are published. A complaint
+// aborts the key generation.
+func (r *RpcService) DKGFinish(args DKGFinishArgs, reply *bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, err := r.dkgState()
+	if err != nil {
+		return err
+	}
+	i, ok := s.ids[args.ID]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", args.ID)
+	}
+	if len(args.Complaints) > 0 {
+		s.failed = fmt.Errorf("drone %s complains about the dealings of %v", args.ID, args.Complaints)
+		fmt.Println("DKG abort:", s.failed)
+		return s.failed
+	}
+	dealings, err := s.decodeDealings()
+	if err != nil {
+		return err
+	}
+	Y := s.dkg.Group.NewElement()
+	if err := Y.UnmarshalBinary(args.Y); err != nil {
+		return err
+	}
+	if err := s.dkg.VerifyShareCommitment(dealings, i, Y); err != nil {
+		return err
+	}
+	s.Y[i] = Y
+	for _, y := range s.Y {
+		if y == nil {
+			*reply = true
+			return nil
+		}
+	}
+	pub, err := s.dkg.PublicKey(dealings)
+	if err != nil {
+		return err
+	}
+	r.params = s.dkg.Params(pub, s.Y)
+	fmt.Printf("DKG done, params.Pub: %x\n", pubBytes(r.params))
+	fmt.Printf("params.ThresholdT2: %v\n", r.params.ThresholdT2)
+	*reply = true
+	return nil
+}
+
+// dkgState returns the key generation in progress
+func (r *RpcService) dkgState() (*dkgState, error) {
+	if r.dkg == nil {
+		return nil, errors.New("the TA is the dealer, there is no key generation")
+	}
+	if r.dkg.failed != nil {
+		return nil, r.dkg.failed
+	}
+	if r.params != nil {
+		return nil, errors.New("the key generation has finished")
+	}
+	return r.dkg, nil
+}
+
+// committed reports whether every drone has committed to its dealing
+func (s *dkgState) committed() bool {
+	return !slices.ContainsFunc(s.digests, func(d []byte) bool { return d == nil })
+}
+
+// decodeDealings decodes the dealings of all the drones
+func (s *dkgState) decodeDealings() ([]*scheme.DKGDealing, error) {
+	dealings := make([]*scheme.DKGDealing, 0, len(s.dealings))
+	for _, data := range s.dealings {
+		d := new(scheme.DKGDealing)
+		if err := d.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		dealings = append(dealings, d)
+	}
+	return dealings, nil
+}
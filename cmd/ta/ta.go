@@ -1,77 +1,479 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net"
 	"net/rpc"
-	"sync/atomic"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
 
 	"github.com/52funny/scheme"
-	"github.com/ncw/gmp"
+	"github.com/52funny/scheme/bigint"
 )
 
 type RpcService struct {
-	crt *scheme.CRTSharing
-	idx atomic.Int32
+	crt      *scheme.CRTSharing  // Dealer without the secret, nil if the drones generated the key or once it is wiped
+	params   *scheme.GroupParams // Public parameters, nil until the key is generated
+	proof    []byte              // Encoded dealing proof
+	mux      sync.Mutex
+	ids      map[string]*bigint.Int // Modulus of every registered drone
+	assigned map[string]bool        // Moduli handed out to a drone
+	pending  map[string]*bigint.Int // Refresh offsets not yet fetched by the drones
+	epoch    int                    // Number of refreshes
+	dkg      *dkgState              // Key generation of the drones, nil if the TA is the dealer
+	rk       *scheme.RevocationKey  // Key the revocation lists are signed with
+	version  uint64                 // Version of the revocation list
 }
 
 // Parameters returned during registration
 type ShareParams struct {
-	ID        string   // UUID V4
-	Weight    int      // Weight
-	Modulus   *gmp.Int // Modulus
-	Remainder *gmp.Int // Reminder
-	Pub       []byte   // Public key
+	ID        string         // UUID V4
+	Weight    int            // Weight
+	Modulus   *bigint.Int    // Modulus
+	Remainder *bigint.Int    // Reminder
+	Pub       []byte         // Public key
+	Group     scheme.GroupID // Group of the public key
+	Proof     []byte         // Dealing proof to verify the remainder
 }
 
+// EnrollArgs are the arguments of a drone joining after the dealing
+type EnrollArgs struct {
+	ID     string // UUID V4
+	Weight int    // Weight of the new modulus
+}
+
+// RefreshArgs are the arguments of a drone fetching its refresh offset
+type RefreshArgs struct {
+	ID    string // UUID V4
+	Epoch int    // Epoch the proof is bound to
+	Proof []byte // Proof that the drone holds its current remainder
+}
+
+// RefreshParams is the offset a drone adds to its remainder after a refresh
+type RefreshParams struct {
+	Epoch  int         // Number of refreshes
+	Offset *bigint.Int // Offset to add to the remainder
+	Proof  []byte      // Dealing proof to verify the new remainder
+}
+
+// NewRegisterService serves the shares of the sharing. The secret is dropped
+// right away and only restored from the remainders while it is needed.
 func NewRegisterService(crt *scheme.CRTSharing) *RpcService {
+	proof, err := crt.Proof().MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	crt.DropSecret()
 	srv := &RpcService{
-		crt: crt,
+		crt:      crt,
+		params:   crt.Params(),
+		proof:    proof,
+		ids:      make(map[string]*bigint.Int),
+		assigned: make(map[string]bool),
+		pending:  make(map[string]*bigint.Int),
+		rk:       scheme.NewRevocationKey(crt.Group),
 	}
-	srv.idx.Store(0)
 	return srv
 }
 
 // Register registers a new participant
 func (r *RpcService) Register(id string, reply *ShareParams) error {
-	// Increment the index using atomic operations
-	current := r.idx.Add(1) - 1
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.dealer(); err != nil {
+		return err
+	}
 
-	if int(current) >= len(r.crt.Weight) {
+	// The first modulus not handed out yet
+	current := slices.IndexFunc(r.crt.Moduli, func(m *bigint.Int) bool {
+		return !r.assigned[m.String()] && !r.crt.IsRevoked(m)
+	})
+	if current < 0 {
 		return fmt.Errorf("The number of participants has reached the upper limit")
 	}
+	*reply = *r.assign(id, current)
+	fmt.Println("Register id:", id, " weight:", reply.Weight, " modulus:", reply.Modulus)
+	r.release()
+	return nil
+}
 
-	params := &ShareParams{
-		ID:        id,
-		Weight:    r.crt.Weight[current],
-		Modulus:   r.crt.Moduli[current],
-		Remainder: r.crt.Remainder[current],
-		Pub:       r.crt.Pub.BytesCompressed(),
+// Enroll adds a new drone with a fresh modulus of the requested weight,
+// the shares of the registered drones and the public key stay the same.
+// Weights below scheme.MinWeight are rejected.
+func (r *RpcService) Enroll(args EnrollArgs, reply *ShareParams) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.dealer(); err != nil {
+		return err
+	}
+	var m *bigint.Int
+	err := r.withSecret(func() (err error) {
+		m, _, err = r.crt.Enroll(args.Weight)
+		return err
+	})
+	if err != nil {
+		return err
 	}
-	fmt.Println("Register id:", id, " weight:", params.Weight, " modulus:", params.Modulus, " remainder:", params.Remainder)
-	*reply = *params
+	proof, err := r.crt.Proof().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	r.proof = proof
+	r.params = r.crt.Params()
+	*reply = *r.assign(args.ID, r.index(m))
+	fmt.Println("Enroll id:", args.ID, " weight:", reply.Weight, " modulus:", reply.Modulus, " ThresholdT2:", r.crt.ThresholdT2)
+	r.release()
 	return nil
 }
 
+// assign hands the i-th share out to the drone
+func (r *RpcService) assign(id string, i int) *ShareParams {
+	m := r.crt.Moduli[i]
+	r.ids[id] = m
+	r.assigned[m.String()] = true
+	return &ShareParams{
+		ID:        id,
+		Weight:    r.crt.Weight[i],
+		Modulus:   m,
+		Remainder: r.crt.Remainder[i],
+		Pub:       pubBytes(r.params),
+		Group:     r.crt.Group.ID(),
+		Proof:     r.proof,
+	}
+}
+
+// index returns the index of the modulus in the sharing
+func (r *RpcService) index(m *bigint.Int) int {
+	return slices.IndexFunc(r.crt.Moduli, func(x *bigint.Int) bool { return x.Cmp(m) == 0 })
+}
+
 // GetPublicKey returns the public key
 func (r *RpcService) GetPublicKey(args int, reply *[]byte) error {
-	pub := r.crt.Pub.BytesCompressed()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.params == nil {
+		return errNoSharing
+	}
+	*reply = pubBytes(r.params)
+	return nil
+}
+
+// GetGroupParams returns the encoded public parameters of the sharing
+func (r *RpcService) GetGroupParams(args int, reply *[]byte) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.params == nil {
+		return errNoSharing
+	}
+	// The share commitments are not published, the remainder of a small
+	// modulus is found from its commitment by a search
+	params := r.params.Clone()
+	params.Commitments = nil
+	data, err := params.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+// GetSigningCommitments returns the compressed signing commitments of the
+// drones with the moduli, the aggregator verifies their partial signatures
+// against them to find the faulty drones. The moduli must form a signing set,
+// the commitment of a single drone would reveal its remainder. They are
+// computed from the remainders, so they are gone once the dealer is wiped.
+func (r *RpcService) GetSigningCommitments(moduli []*bigint.Int, reply *[][]byte) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.dealer(); err != nil {
+		return err
+	}
+	U, err := r.crt.SigningCommitments(moduli)
+	if err != nil {
+		return err
+	}
+	res := make([][]byte, 0, len(U))
+	for _, u := range U {
+		b, _ := u.MarshalBinaryCompress()
+		res = append(res, b)
+	}
+	*reply = res
+	return nil
+}
+
+// Refresh re-randomizes all the shares, the public key stays the same.
+// The registered drones fetch their offsets with GetRefresh.
+func (r *RpcService) Refresh(args int, reply *int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.dealer(); err != nil {
+		return err
+	}
+	var offsets []*bigint.Int
+	err := r.withSecret(func() (err error) {
+		offsets, err = r.crt.Refresh()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	proof, err := r.crt.Proof().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	r.proof = proof
+	r.params = r.crt.Params()
+
+	// Accumulate the offsets, a drone may miss a refresh
+	for id, m := range r.ids {
+		if r.crt.IsRevoked(m) {
+			continue
+		}
+		i := r.index(m)
+		offset, ok := r.pending[id]
+		if !ok {
+			offset = new(bigint.Int)
+			r.pending[id] = offset
+		}
+		offset.Add(offset, offsets[i])
+		offset.Mod(offset, r.crt.Moduli[i])
+	}
+	r.epoch++
+	fmt.Println("Refresh epoch:", r.epoch)
+	*reply = r.epoch
+	return nil
+}
+
+// GetRefresh returns the pending refresh offset of the drone. The drone
+// proves that it holds the remainder before the refresh, knowing its ID is
+// not enough.
+func (r *RpcService) GetRefresh(args RefreshArgs, reply *RefreshParams) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.dealer(); err != nil {
+		return err
+	}
+	id := args.ID
+	m, ok := r.ids[id]
+	if !ok {
+		return fmt.Errorf("unknown drone %s", id)
+	}
+	if r.crt.IsRevoked(m) {
+		return fmt.Errorf("drone %s is revoked", id)
+	}
+	if args.Epoch != r.epoch {
+		return fmt.Errorf("proof of drone %s is for epoch %d, not %d", id, args.Epoch, r.epoch)
+	}
+	offset, ok := r.pending[id]
+	if !ok {
+		offset = new(bigint.Int)
+	}
+
+	// The drone still holds the remainder without the pending offset
+	i := r.index(m)
+	current := new(bigint.Int).Sub(r.crt.Remainder[i], offset)
+	current.Mod(current, m)
+	g := r.crt.Group
+	Y := g.NewElement().MulGen(scheme.IntToScalar(g, current))
+	bigint.Clear(current)
+	proof := new(scheme.Signature)
+	if err := proof.UnmarshalBinary(args.Proof); err != nil {
+		return err
+	}
+	if err := scheme.VerifyShareProof(Y, m, refreshContext(id, args.Epoch), proof); err != nil {
+		return err
+	}
+	delete(r.pending, id)
+	*reply = RefreshParams{Epoch: r.epoch, Offset: offset, Proof: r.proof}
+	r.release()
+	return nil
+}
+
+// refreshContext returns the context the drone proves its share for, the
+// proof is good for one drone and one epoch
+func refreshContext(id string, epoch int) []byte {
+	return []byte(fmt.Sprintf("refresh %s %d", id, epoch))
+}
+
+// Revoke revokes the share of the modulus and returns the new signed
+// revocation list. Without the dealer the signers must exceed the recovery
+// bound instead of the secret, and the revoked share is not refreshed away.
+func (r *RpcService) Revoke(modulus *bigint.Int, reply *[]byte) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.params == nil {
+		return errNoSharing
+	}
+	if r.crt != nil {
+		if err := r.withSecret(func() error { return r.crt.Revoke(modulus) }); err != nil {
+			return err
+		}
+		r.params = r.crt.Params()
+	} else if err := r.params.Revoke(modulus); err != nil {
+		return err
+	}
+	r.version++
+	fmt.Println("Revoke modulus:", modulus, " ThresholdT1:", r.params.ThresholdT1, " ThresholdT2:", r.params.ThresholdT2)
+	r.release()
+	return r.revocationList(reply)
+}
+
+// GetSignBound returns PMin2, any set of drones whose moduli multiply to at
+// least PMin2 can sign
+func (r *RpcService) GetSignBound(args int, reply *bigint.Int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.params == nil {
+		return errNoSharing
+	}
+	reply.Set(r.params.PMin2)
+	return nil
+}
+
+// GetEpoch returns the number of refreshes
+func (r *RpcService) GetEpoch(args int, reply *int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	*reply = r.epoch
+	return nil
+}
+
+// GetRevocationList returns the signed revocation list
+func (r *RpcService) GetRevocationList(args int, reply *[]byte) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.params == nil {
+		return errNoSharing
+	}
+	return r.revocationList(reply)
+}
+
+// GetRevocationKey returns the compressed public key of the revocation lists
+func (r *RpcService) GetRevocationKey(args int, reply *[]byte) error {
+	pub, err := r.rk.Pub.MarshalBinaryCompress()
+	if err != nil {
+		return err
+	}
 	*reply = pub
 	return nil
 }
 
+func (r *RpcService) revocationList(reply *[]byte) error {
+	data, err := r.params.RevocationList(r.rk, r.version).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+// dealer returns an error unless the TA dealt the secret itself and still
+// holds the remainders
+func (r *RpcService) dealer() error {
+	if r.crt != nil {
+		return nil
+	}
+	if r.params == nil {
+		return errNoSharing
+	}
+	if r.dkg == nil {
+		return errWiped
+	}
+	return errNoDealer
+}
+
+// withSecret restores the secret of the dealer for f and drops it again
+func (r *RpcService) withSecret(f func() error) error {
+	if err := r.crt.RestoreSecret(); err != nil {
+		return err
+	}
+	defer r.crt.DropSecret()
+	return f()
+}
+
+// release wipes the dealer once every active share is handed out and every
+// refresh offset is fetched, only the public parameters are kept afterwards
+func (r *RpcService) release() {
+	if r.crt == nil {
+		return
+	}
+	for _, m := range r.crt.ActiveModuli() {
+		if !r.assigned[m.String()] {
+			return
+		}
+	}
+	for id := range r.pending {
+		if !r.crt.IsRevoked(r.ids[id]) {
+			return
+		}
+	}
+	r.wipe()
+}
+
+// wipe overwrites the remainders of the dealer and the pending refresh offsets
+func (r *RpcService) wipe() {
+	if r.crt != nil {
+		r.crt.Wipe()
+		r.crt = nil
+		fmt.Println("Dealer wiped, every share is handed out")
+	}
+	for id, offset := range r.pending {
+		bigint.Clear(offset)
+		delete(r.pending, id)
+	}
+}
+
+// wipeOnExit wipes the dealer when the process is interrupted or terminated
+func wipeOnExit(srv *RpcService) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		srv.mux.Lock()
+		srv.wipe()
+		os.Exit(1)
+	}()
+}
+
+// pubBytes returns the compressed public key
+func pubBytes(params *scheme.GroupParams) []byte {
+	pub, _ := params.Pub.MarshalBinaryCompress()
+	return pub
+}
+
+// Number of drones generating the key without a dealer, 0 if the TA deals
+var dkgParties = flag.Int("dkg", 0, "let this many drones generate the key without a dealer")
+
 func main() {
-	weight_opts := []int{16, 128, 512}
+	flag.Parse()
+	// A partial signature reveals u_i * G with u_i < P, the remainder of a
+	// modulus below 256 bits is found from it by a search
+	weight_opts := []int{256, 384, 512}
 	n := 100
-
-	moduli := scheme.GenerateNumber(weight_opts, n)
 	t := 3
-	crt := scheme.NewCRTSharing(n, t, moduli)
-	fmt.Printf("crt.Pub: %x\n", crt.Pub.BytesCompressed())
-	fmt.Printf("crt.ThresholdT2: %v\n", crt.ThresholdT2)
 
-	srv := NewRegisterService(crt)
+	var srv *RpcService
+	if *dkgParties > 0 {
+		moduli := scheme.GenerateNumber([]int{512}, *dkgParties)
+		dkg, err := scheme.NewDKG(scheme.DefaultGroup, t, moduli)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("dkg.ThresholdT2: %v\n", dkg.ThresholdT2)
+		srv = NewDKGService(dkg)
+	} else {
+		moduli := scheme.GenerateNumber(weight_opts, n)
+		crt := scheme.NewCRTSharing(n, t, moduli)
+		fmt.Printf("crt.Pub: %x\n", pubBytes(&crt.GroupParams))
+		fmt.Printf("crt.ThresholdT2: %v\n", crt.ThresholdT2)
+		srv = NewRegisterService(crt)
+	}
 
+	wipeOnExit(srv)
 	rpc.RegisterName("RpcService", srv)
 	listener, err := net.Listen("tcp", ":1234")
 	if err != nil {
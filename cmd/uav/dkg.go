@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+)
+
+// DKGJoinArgs are the arguments of a drone joining the key generation
+type DKGJoinArgs struct {
+	ID       string // UUID V4
+	Identity []byte // Ed25519 identity the other drones pinned
+}
+
+// DKGBindArgs is the X25519 key of a drone signed by its identity
+type DKGBindArgs struct {
+	ID        string // UUID V4
+	Key       []byte // X25519 public key to encrypt the shares to the drone
+	Signature []byte // Signature of the key and the modulus by the identity
+}
+
+// DKGKeys are the identities and the signed keys of all the drones
+type DKGKeys struct {
+	Identities [][]byte // Ed25519 identities in the order of the moduli
+	Keys       [][]byte // X25519 public keys in the order of the moduli
+	Signatures [][]byte // Signatures of the keys by the identities
+}
+
+// DKGParams are the public parameters of the key generation
+type DKGParams struct {
+	Modulus   *bigint.Int    // Modulus of the drone
+	Moduli    []*bigint.Int  // Moduli of all the drones
+	Threshold int            // The threshold t
+	Group     scheme.GroupID // Group of the public key
+}
+
+// DKGCommitArgs is the digest of the dealing of a drone, sent before any
+// dealing is revealed
+type DKGCommitArgs struct {
+	ID     string // UUID V4
+	Digest []byte // Digest of the public part of the dealing
+}
+
+// DKGDealArgs is the dealing of a drone
+type DKGDealArgs struct {
+	ID      string   // UUID V4
+	Dealing []byte   // Public part of the dealing
+	Shares  [][]byte // Encrypted shares in the order of the moduli
+}
+
+// DKGShares are the dealings of all the drones and the shares encrypted for one of them
+type DKGShares struct {
+	Dealings [][]byte // Public parts of the dealings
+	Shares   [][]byte // Shares encrypted for the drone, one per dealer
+}
+
+// DKGFinishArgs is the share commitment of a drone, or its complaints
+type DKGFinishArgs struct {
+	ID         string // UUID V4
+	Y          []byte // Share commitment r * G
+	Complaints []int  // Indices of the dealers whose share did not verify
+}
+
+// Interval between two polls of the TA while waiting for the other drones
+const dkgPoll = 500 * time.Millisecond
+
+// generate runs the key generation with the other drones, the TA only
+// relays the messages. The returned parameters carry no dealing proof,
+// every dealing was verified on its own.
+func generate(client *rpc.Client, id string) (ShareParams, error) {
+	identity, err := loadIdentity(*identityFile)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	peers, err := loadPeers(*peersFile)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	var params DKGParams
+	join := DKGJoinArgs{ID: id, Identity: identity.Public().(ed25519.PublicKey)}
+	if err := client.Call("RpcService.DKGJoin", join, &params); err != nil {
+		return ShareParams{}, err
+	}
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	bind := DKGBindArgs{ID: id, Key: key.PublicKey().Bytes(), Signature: scheme.SignDKGKey(identity, key.PublicKey(), params.Modulus)}
+	var ok bool
+	if err := client.Call("RpcService.DKGBind", bind, &ok); err != nil {
+		return ShareParams{}, err
+	}
+	g, err := scheme.GroupByID(params.Group)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	dkg, err := scheme.NewDKG(g, params.Threshold, params.Moduli)
+	if err != nil {
+		return ShareParams{}, err
+	}
+
+	// Encrypt a share of our contribution to every drone, under the keys
+	// signed by the pinned identities only
+	var signed DKGKeys
+	if err := poll(client, "RpcService.DKGKeys", 0, &signed); err != nil {
+		return ShareParams{}, err
+	}
+	keys, err := verifyKeys(dkg, peers, signed)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	own := slices.IndexFunc(dkg.Moduli, func(m *bigint.Int) bool { return m.Cmp(params.Modulus) == 0 })
+	if own < 0 || !keys[own].Equal(key.PublicKey()) {
+		return ShareParams{}, errors.New("the TA replaced the key of this drone")
+	}
+	dealing := dkg.Deal()
+	deal := DKGDealArgs{ID: id, Shares: make([][]byte, 0, dkg.N)}
+	for i, to := range keys {
+		ct, err := scheme.EncryptShare(key, to, dkg.Moduli[i], dealing.Shares[i])
+		if err != nil {
+			return ShareParams{}, err
+		}
+		deal.Shares = append(deal.Shares, ct)
+	}
+	if deal.Dealing, err = dealing.MarshalBinary(); err != nil {
+		return ShareParams{}, err
+	}
+
+	// Commit to the dealing, it is revealed once every drone has committed
+	digest, err := dealing.Digest()
+	if err != nil {
+		return ShareParams{}, err
+	}
+	if err := client.Call("RpcService.DKGCommit", DKGCommitArgs{ID: id, Digest: digest}, &ok); err != nil {
+		return ShareParams{}, err
+	}
+	var digests [][]byte
+	if err := poll(client, "RpcService.DKGCommits", 0, &digests); err != nil {
+		return ShareParams{}, err
+	}
+	if len(digests) != dkg.N || !bytes.Equal(digests[own], digest) {
+		return ShareParams{}, errors.New("the TA changed the commitments to the dealings")
+	}
+	if err := poll(client, "RpcService.DKGDeal", deal, &ok); err != nil {
+		return ShareParams{}, err
+	}
+
+	// Verify the share of every dealer and sum them up
+	var res DKGShares
+	if err := poll(client, "RpcService.DKGShares", id, &res); err != nil {
+		return ShareParams{}, err
+	}
+	dealings := make([]*scheme.DKGDealing, 0, dkg.N)
+	shares := make([]*bigint.Int, 0, dkg.N)
+	var complaints []int
+	if len(res.Dealings) != dkg.N || len(res.Shares) != dkg.N {
+		return ShareParams{}, fmt.Errorf("got %d dealings for %d drones", len(res.Dealings), dkg.N)
+	}
+	for j := range res.Dealings {
+		d := new(scheme.DKGDealing)
+		share, err := receive(dkg, key, params.Modulus, keys[j], digests[j], res.Dealings[j], res.Shares[j], d)
+		if err != nil {
+			fmt.Println("DKG dealing", j, "error:", err)
+			complaints = append(complaints, j)
+		}
+		dealings = append(dealings, d)
+		shares = append(shares, share)
+	}
+	if len(complaints) > 0 {
+		client.Call("RpcService.DKGFinish", DKGFinishArgs{ID: id, Complaints: complaints}, &ok)
+		return ShareParams{}, fmt.Errorf("invalid dealings %v", complaints)
+	}
+	remainder, err := dkg.Remainder(params.Modulus, shares)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	pub, err := dkg.PublicKey(dealings)
+	if err != nil {
+		return ShareParams{}, err
+	}
+	Y := g.NewElement().MulGen(scheme.IntToScalar(g, remainder))
+	if err := client.Call("RpcService.DKGFinish", DKGFinishArgs{ID: id, Y: compress(Y)}, &ok); err != nil {
+		return ShareParams{}, err
+	}
+	return ShareParams{
+		ID:        id,
+		Weight:    params.Modulus.BitLen(),
+		Modulus:   params.Modulus,
+		Remainder: remainder,
+		Pub:       compress(pub),
+		Group:     params.Group,
+	}, nil
+}
+
+// receive decodes the dealing into d, checks it against the digest the dealer
+// committed to, decrypts the share of the dealer and verifies it
+func receive(dkg *scheme.DKG, key *ecdh.PrivateKey, modulus *bigint.Int, dealer *ecdh.PublicKey, digest, data, ct []byte, d *scheme.DKGDealing) (*bigint.Int, error) {
+	if err := d.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if got, err := d.Digest(); err != nil || !bytes.Equal(got, digest) {
+		return nil, errors.New("dealing does not match the commitment")
+	}
+	share, err := scheme.DecryptShare(key, dealer, modulus, ct)
+	if err != nil {
+		return nil, err
+	}
+	if err := dkg.VerifyShare(d, modulus, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// verifyKeys checks that every key is signed by a distinct pinned identity
+// for the modulus of its drone and returns the keys in the order of the moduli
+func verifyKeys(dkg *scheme.DKG, peers []ed25519.PublicKey, res DKGKeys) ([]*ecdh.PublicKey, error) {
+	if len(res.Identities) != dkg.N || len(res.Keys) != dkg.N || len(res.Signatures) != dkg.N {
+		return nil, fmt.Errorf("got %d keys for %d drones", len(res.Keys), dkg.N)
+	}
+	keys := make([]*ecdh.PublicKey, 0, dkg.N)
+	for j, identity := range res.Identities {
+		if !slices.ContainsFunc(peers, func(p ed25519.PublicKey) bool { return p.Equal(ed25519.PublicKey(identity)) }) {
+			return nil, fmt.Errorf("identity %x of drone %d is not pinned", identity, j)
+		}
+		if slices.ContainsFunc(res.Identities[:j], func(other []byte) bool { return bytes.Equal(other, identity) }) {
+			return nil, fmt.Errorf("identity %x joined twice", identity)
+		}
+		key, err := ecdh.X25519().NewPublicKey(res.Keys[j])
+		if err != nil {
+			return nil, err
+		}
+		if err := scheme.VerifyDKGKey(identity, key, dkg.Moduli[j], res.Signatures[j]); err != nil {
+			return nil, fmt.Errorf("key of drone %d: %w", j, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadIdentity reads the hex Ed25519 seed of the drone
+func loadIdentity(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		return nil, errors.New("-dkg needs the identity of the drone, see -keygen")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s holds no hex Ed25519 seed", path)
+	}
+	defer clear(seed)
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// loadPeers reads the pinned hex Ed25519 identities of the drones, one per line
+func loadPeers(path string) ([]ed25519.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("-dkg needs the pinned identities of the drones")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var peers []ed25519.PublicKey
+	for _, line := range strings.Fields(string(data)) {
+		pub, err := hex.DecodeString(line)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: invalid identity %q", path, line)
+		}
+		peers = append(peers, pub)
+	}
+	return peers, nil
+}
+
+// keygen writes a fresh identity to the file and returns its public key
+func keygen(path string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(priv.Seed())); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// poll calls the method until the other drones have caught up
+func poll(client *rpc.Client, method string, args any, reply any) error {
+	for {
+		err := client.Call(method, args, reply)
+		if err == nil || err.Error() != "waiting for the other drones" {
+			return err
+		}
+		time.Sleep(dkgPoll)
+	}
+}
@@ -3,26 +3,53 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/rpc"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/52funny/scheme"
-	"github.com/cloudflare/circl/ecc/bls12381"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/ncw/gmp"
 )
 
 // Parameters returned during registration
 type ShareParams struct {
-	ID        string   // UUID V4
-	Weight    int      // Weight
-	Modulus   *gmp.Int // Modulus
-	Remainder *gmp.Int // Reminder
-	Pub       []byte   // Public key
+	ID        string         // UUID V4
+	Weight    int            // Weight
+	Modulus   *bigint.Int    // Modulus
+	Remainder *bigint.Int    // Reminder
+	Pub       []byte         // Public key
+	Group     scheme.GroupID // Group of the public key
+	Proof     []byte         // Dealing proof to verify the remainder
+}
+
+// EnrollArgs are the arguments of a drone joining after the dealing
+type EnrollArgs struct {
+	ID     string // UUID V4
+	Weight int    // Weight of the new modulus
+}
+
+// RefreshArgs are the arguments of a drone fetching its refresh offset
+type RefreshArgs struct {
+	ID    string // UUID V4
+	Epoch int    // Epoch the proof is bound to
+	Proof []byte // Proof that the drone holds its current remainder
+}
+
+// RefreshParams is the offset a drone adds to its remainder after a refresh
+type RefreshParams struct {
+	Epoch  int         // Number of refreshes
+	Offset *bigint.Int // Offset to add to the remainder
+	Proof  []byte      // Dealing proof to verify the new remainder
 }
 
 type Message struct {
@@ -32,29 +59,53 @@ type Message struct {
 
 // UavPubMessage is the parameter that the drone gives to the aggregator
 type UavPubMessage struct {
-	ID string
-	E  []byte
-	D  []byte
-	P  *gmp.Int
+	ID   string
+	P    *bigint.Int
+	Cost float64 // Cost hint of the drone, 0 if unknown
+}
+
+// CommitRequestMessage opens the commit round of a signing session, the
+// drones of the signing set derive their nonces from the session, the message
+// and the moduli of the set
+type CommitRequestMessage struct {
+	Session string                // Session ID
+	Context scheme.SessionContext // Context the drones bind the signature to
+	Msg     string
+	Moduli  []*bigint.Int // Moduli of the signing set
+}
+
+// CommitMessage is the fresh nonce commitment (E, D) of a drone for a session
+type CommitMessage struct {
+	ID      string
+	Session string      // Session ID of the commit round
+	P       *bigint.Int // Modulus of the drone
+	Index   uint64      // Index of the nonce pair in the pool of the drone
+	E       []byte
+	D       []byte
 }
 
 // SignPrepMessage is the message that the aggregator sends to the drone
 type SignPrepMessage struct {
-	Msg string
-	B   B
+	Session string                // Session ID of the commitments in B
+	Context scheme.SessionContext // Context the drones bind the signature to
+	Msg     string
+	B       B
 }
 
 // SignResultMessage is the message that the drone sends to the aggregator
 // (s, R) schnorr signature
 type SignResultMessage struct {
-	S *gmp.Int
-	R []byte
+	Session string      // Session ID
+	P       *bigint.Int // Modulus of the drone
+	S       *bigint.Int
+	R       []byte
 }
 
 type BItem struct {
-	P *gmp.Int // The prime number
-	E []byte   // The E
-	D []byte   // The D
+	P     *bigint.Int // The prime number
+	E     []byte      // The E
+	D     []byte      // The D
+	Nonce uint64      // Index of the nonce pair
 }
 
 type B []BItem
@@ -62,7 +113,50 @@ type B []BItem
 // WebSocket server address
 const WebSocketServer = "ws://localhost:2345"
 
+// Weight of a fresh modulus, a replacement drone enrolls instead of registering
+var enroll = flag.Int("enroll", 0, "enroll with a fresh modulus of this weight, at least 256 bits")
+
+// Cost hint for the aggregator, such as the relative signing time of the drone
+var cost = flag.Float64("cost", 0, "cost hint for the signing set selection")
+
+// Nonce pairs committed to but not yet spent survive restarts in the pool file,
+// which is not encrypted, the keystore keeps them encrypted instead
+var poolFile = flag.String("pool", "", "unencrypted file that keeps the nonce pool across restarts, not with -keystore")
+
+// Secret key of derived nonces for boards with poor entropy
+var nonceKey = flag.String("noncekey", "", "hex key to derive the nonce pairs from, hedged with crypto/rand")
+
+// Generate the key with the other drones instead of registering at the dealer
+var dkgMode = flag.Bool("dkg", false, "generate the key with the other drones, the TA must run with -dkg")
+
+// Long-term identity of the drone signing its key generation key, and the
+// identities of all the drones pinned out of band
+var identityFile = flag.String("identity", "", "file with the hex Ed25519 seed of the drone, required with -dkg")
+var peersFile = flag.String("peers", "", "file with the hex Ed25519 identities of all the drones, required with -dkg")
+var keygenMode = flag.Bool("keygen", false, "write a fresh identity to -identity, print its public key and exit")
+
+// The share survives reboots in the keystore, encrypted under the passphrase of the environment
+var keystore = flag.String("keystore", "", "encrypted file that keeps the share, the passphrase is read from "+passphraseEnv)
+
+// Environment variable with the passphrase of the keystore
+const passphraseEnv = "UAV_PASSPHRASE"
+
 func main() {
+	flag.Parse()
+	if *keygenMode {
+		pub, err := keygen(*identityFile)
+		if err != nil {
+			log.Fatal("keygen:", err)
+		}
+		fmt.Println(hex.EncodeToString(pub))
+		return
+	}
+	if *poolFile != "" && *keystore != "" {
+		log.Fatal("-pool writes the nonces in the clear, the keystore already keeps them")
+	}
+	if *nonceKey != "" && *poolFile == "" && *keystore == "" {
+		log.Fatal("-noncekey needs -keystore or -pool to remember the sessions across restarts")
+	}
 	client, err := rpc.Dial("tcp", "localhost:1234")
 	if err != nil {
 		log.Fatal("dialing:", err)
@@ -70,49 +164,90 @@ func main() {
 
 	var secret ShareParams
 	id := uuid.New().String()
-	err = client.Call("RpcService.Register", id, &secret)
+	stored, err := loadShare()
+	if err != nil {
+		log.Fatal("keystore:", err)
+	}
+	if stored != nil {
+		// A drone with a keystore keeps its index instead of registering again
+		id = stored.ID
+		secret = ShareParams{
+			ID:        stored.ID,
+			Weight:    stored.Modulus.BitLen(),
+			Modulus:   stored.Modulus,
+			Remainder: stored.Remainder,
+			Pub:       compress(stored.Pub),
+			Group:     stored.Group().ID(),
+		}
+	} else if *dkgMode {
+		secret, err = generate(client, id)
+	} else if *enroll > 0 {
+		err = client.Call("RpcService.Enroll", EnrollArgs{ID: id, Weight: *enroll}, &secret)
+	} else {
+		err = client.Call("RpcService.Register", id, &secret)
+	}
 	if err != nil {
 		log.Fatal("register error:", err)
 	}
-	fmt.Println("Register id:", id, " weight:", secret.Weight, " modulus:", secret.Modulus, " remainder:", secret.Remainder)
+	fmt.Println("Register id:", id, " weight:", secret.Weight, " modulus:", secret.Modulus)
 
-	e := scheme.GenerateScalar()
-	d := scheme.GenerateScalar()
-	E := new(bls12381.G1)
-	E.ScalarMult(e, bls12381.G1Generator())
-	D := new(bls12381.G1)
-	D.ScalarMult(d, bls12381.G1Generator())
+	g, err := scheme.GroupByID(secret.Group)
+	if err != nil {
+		log.Fatal("group:", err)
+	}
+	pub := g.NewElement()
+	if err := pub.UnmarshalBinary(secret.Pub); err != nil {
+		log.Fatal("public key:", err)
+	}
 
-	// Parameters to be sent to the aggregator
-	bItem := scheme.BItem{
-		E: E,
-		D: D,
-		P: secret.Modulus,
+	// Make sure the TA handed out a share of the public key
+	if !*dkgMode && stored == nil {
+		proof := new(scheme.DealingProof)
+		if err := proof.UnmarshalBinary(secret.Proof); err != nil {
+			log.Fatal("dealing proof:", err)
+		}
+		if err := scheme.VerifyShare(pub, secret.Modulus, secret.Remainder, proof); err != nil {
+			log.Fatal("verify share:", err)
+		}
+	}
+	remainder := secret.Remainder
+
+	// Nonce pairs left over from an earlier run are still unspent
+	pool := scheme.NewNoncePool(g)
+	if stored != nil && stored.Pool != nil {
+		pool = stored.Pool
+	} else if *poolFile != "" {
+		if data, err := os.ReadFile(*poolFile); err == nil {
+			if err := pool.UnmarshalBinary(data); err != nil {
+				log.Fatal("nonce pool:", err)
+			}
+		}
+	}
+	pp := scheme.NewPoolSigner(pool, new(bigint.Int).Set(remainder), pub, secret.Modulus)
+	if *nonceKey != "" {
+		key, err := hex.DecodeString(*nonceKey)
+		if err != nil {
+			log.Fatal("nonce key:", err)
+		}
+		pp = scheme.NewDerivedSigner(key, pool, new(bigint.Int).Set(remainder), pub, secret.Modulus)
+	}
+	// The remainder and the nonces do not outlive the drone process
+	defer pp.Destroy()
+	defer func() { bigint.Clear(remainder) }()
+
+	share := &scheme.Share{ID: id, Modulus: secret.Modulus, Remainder: remainder, Pub: pub, Pool: pool}
+	if err := saveState(share); err != nil {
+		log.Fatal("keystore:", err)
 	}
-	pub := new(bls12381.G1)
-	pub.SetBytes(secret.Pub)
-	pp := scheme.NewSigner(e, d, secret.Remainder, pub, bItem)
 
-	fmt.Printf("pp.Pub: %x\n", pp.Pub.BytesCompressed())
+	fmt.Printf("pp.Pub: %x\n", compress(pp.Pub))
 
 	conn, _, err := websocket.DefaultDialer.Dial(WebSocketServer, nil)
 	if err != nil {
 		log.Fatal("dial:", err)
 	}
-	pubMsg := UavPubMessage{
-		ID: id,
-		E:  E.BytesCompressed(),
-		D:  D.BytesCompressed(),
-		P:  secret.Modulus,
-	}
-	buffer := new(bytes.Buffer)
-	gob.NewEncoder(buffer).Encode(pubMsg)
-	msg := Message{
-		Type: "PARAMS",
-		Data: buffer.Bytes(),
-	}
-	err = conn.WriteJSON(msg)
-	if err != nil {
+	// Parameters to be sent to the aggregator
+	if err := sendParams(conn, id, secret.Modulus); err != nil {
 		log.Fatal("write:", err)
 	}
 
@@ -120,6 +255,12 @@ func main() {
 	var m string
 	// BList is the list of BItem
 	var BList scheme.B
+	// Commit request and nonce commitment of the last commit round
+	var session string
+	var request CommitRequestMessage
+	var committed scheme.NonceCommitment
+	// Context of the session the signature is bound to
+	var ctx *scheme.SessionContext
 
 	for {
 		_, message, err := conn.ReadMessage()
@@ -130,6 +271,48 @@ func main() {
 		msg := Message{}
 		json.Unmarshal(message, &msg)
 		switch msg.Type {
+		case "COMMIT_REQ":
+			// COMMIT_REQ asks for a fresh nonce commitment for the session
+			req := CommitRequestMessage{}
+			if err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&req); err != nil || req.Session == "" {
+				log.Println("decode: invalid commit request")
+				continue
+			}
+			if !slices.ContainsFunc(req.Moduli, func(p *bigint.Int) bool { return p.Cmp(secret.Modulus) == 0 }) {
+				fmt.Println("Not in the signing set")
+				continue
+			}
+			if string(req.Context.SessionID) != req.Session || !bytes.Equal(req.Context.GroupID, compress(pub)) {
+				log.Println("commit: context of another session or group")
+				continue
+			}
+			// The session is recorded in the pool, which is saved before the
+			// commitment leaves the drone, so no restart derives its pair again
+			if !pool.UseSession([]byte(req.Session)) {
+				log.Println("commit: session", req.Session, "was already committed to")
+				continue
+			}
+			var c scheme.NonceCommitment
+			if *nonceKey != "" {
+				m := scheme.NewMessage([]byte(req.Msg)).WithContext(&req.Context)
+				c, err = pp.DeriveNonceMessage(m, req.Moduli, []byte(req.Session))
+			} else {
+				c = pool.Generate(1)[0]
+			}
+			if err != nil {
+				log.Println("nonce:", err)
+				continue
+			}
+			if err := saveState(share); err != nil {
+				log.Println("nonce pool:", err)
+				return
+			}
+			session, request, committed, BList = req.Session, req, c, nil
+			if err := sendCommit(conn, id, secret.Modulus, session, c); err != nil {
+				log.Println("write:", err)
+				return
+			}
+			fmt.Println("Commit session:", session, " nonce:", c.Index)
 		case "SIGNPREP":
 			// SIGNPREP is the message to prepare the signature
 			fmt.Println("Signature preparation")
@@ -139,30 +322,95 @@ func main() {
 				log.Println("decode:", err)
 				return
 			}
-			BList = transform(prepMsg.B)
+			BList, err = transform(g, prepMsg.B)
+			if err != nil {
+				log.Println("decode:", err)
+				return
+			}
 			m = prepMsg.Msg
 			fmt.Println("B len:", len(BList))
+			// Drones outside the signing set sit this session out
+			i := slices.IndexFunc(BList, func(item scheme.BItem) bool { return item.P.Cmp(secret.Modulus) == 0 })
+			if i < 0 {
+				fmt.Println("Not in the signing set")
+				BList = nil
+				continue
+			}
+			// Only the commitment sent in this session's commit round may be used
+			if session == "" || prepMsg.Session != session || BList[i].Nonce != committed.Index ||
+				!BList[i].E.IsEqual(committed.E) || !BList[i].D.IsEqual(committed.D) {
+				log.Println("signprep: stale commitments for session", prepMsg.Session)
+				BList = nil
+				continue
+			}
+			// The message, the context and the signing set are those the nonce was committed for
+			if prepMsg.Msg != request.Msg || !prepMsg.Context.Equal(&request.Context) || !sameModuli(BList, request.Moduli) {
+				log.Println("signprep: message, context or signing set differ from the commit request")
+				BList = nil
+				continue
+			}
+			ctx = &prepMsg.Context
 			fmt.Println("M:", m)
+		case "REFRESH":
+			// REFRESH re-randomizes the remainder, the public key stays the same
+			params, err := fetchRefresh(client, g, id, secret.Modulus, remainder)
+			if err != nil {
+				log.Println("refresh:", err)
+				continue
+			}
+			proof := new(scheme.DealingProof)
+			if err := proof.UnmarshalBinary(params.Proof); err != nil {
+				log.Println("dealing proof:", err)
+				continue
+			}
+			refreshed := scheme.RefreshShare(secret.Modulus, remainder, params.Offset)
+			if err := scheme.VerifyShare(pub, secret.Modulus, refreshed, proof); err != nil {
+				log.Println("verify share:", err)
+				continue
+			}
+			bigint.Clear(remainder)
+			remainder = refreshed
+			pp.Refresh(params.Offset)
+			share.Remainder = remainder
+			if err := saveState(share); err != nil {
+				log.Println("keystore:", err)
+				return
+			}
+			fmt.Println("Refresh epoch:", params.Epoch)
 		case "SIGN":
 			// SIGN is the message to sign the message
+			if BList == nil {
+				continue
+			}
 			tt := time.Now()
-			s, R := pp.Sign(m, pub, BList)
+			s, R, err := pp.SignMessage(scheme.NewMessage([]byte(m)).WithContext(ctx), pub, BList)
+			if err != nil {
+				log.Println("sign:", err)
+				continue
+			}
 			fmt.Println("Sign Time Cost:", time.Since(tt))
+			// Persist the spent nonce before the signature leaves the drone
+			if err := saveState(share); err != nil {
+				log.Println("nonce pool:", err)
+				return
+			}
 			fmt.Printf("s: %v\n", s)
-			fmt.Printf("R: %x\n", R.BytesCompressed())
+			fmt.Printf("R: %x\n", compress(R))
 			signMsg := SignResultMessage{
-				S: s,
-				R: R.BytesCompressed(),
+				Session: session,
+				P:       secret.Modulus,
+				S:       s,
+				R:       compress(R),
 			}
-			fmt.Println("Signature Size:", len(s.Bytes())+len(R.BytesCompressed()))
+			session, BList = "", nil
+			fmt.Println("Signature Size:", len(s.Bytes())+len(compress(R)))
 			var buffer bytes.Buffer
 			gob.NewEncoder(&buffer).Encode(signMsg)
 			msg := Message{
 				Type: "SIGNRES",
 				Data: buffer.Bytes(),
 			}
-			err := conn.WriteJSON(msg)
-			if err != nil {
+			if err := conn.WriteJSON(msg); err != nil {
 				log.Println("write:", err)
 				return
 			}
@@ -170,19 +418,149 @@ func main() {
 	}
 }
 
-func transform(origin B) scheme.B {
+// fetchRefresh fetches the refresh offset of the drone, proving to the TA
+// that it holds the remainder of the current epoch
+func fetchRefresh(client *rpc.Client, g scheme.Group, id string, modulus, remainder *bigint.Int) (*RefreshParams, error) {
+	var epoch int
+	if err := client.Call("RpcService.GetEpoch", 0, &epoch); err != nil {
+		return nil, err
+	}
+	context := []byte(fmt.Sprintf("refresh %s %d", id, epoch))
+	proof, err := scheme.ProveShare(g, modulus, remainder, context).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	params := new(RefreshParams)
+	args := RefreshArgs{ID: id, Epoch: epoch, Proof: proof}
+	if err := client.Call("RpcService.GetRefresh", args, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// sameModuli reports whether the moduli of B are exactly the moduli
+func sameModuli(B scheme.B, moduli []*bigint.Int) bool {
+	if len(B) != len(moduli) {
+		return false
+	}
+	for _, item := range B {
+		if !slices.ContainsFunc(moduli, func(p *bigint.Int) bool { return p.Cmp(item.P) == 0 }) {
+			return false
+		}
+	}
+	return true
+}
+
+// sendParams registers the modulus of the drone at the aggregator
+func sendParams(conn *websocket.Conn, id string, modulus *bigint.Int) error {
+	pubMsg := UavPubMessage{
+		ID:   id,
+		P:    modulus,
+		Cost: *cost,
+	}
+	return send(conn, "PARAMS", pubMsg)
+}
+
+// sendCommit sends the nonce commitment of the session to the aggregator
+func sendCommit(conn *websocket.Conn, id string, modulus *bigint.Int, session string, c scheme.NonceCommitment) error {
+	commitMsg := CommitMessage{
+		ID:      id,
+		Session: session,
+		P:       modulus,
+		Index:   c.Index,
+		E:       compress(c.E),
+		D:       compress(c.D),
+	}
+	return send(conn, "COMMIT", commitMsg)
+}
+
+// send writes the gob encoded data as a message of the type
+func send(conn *websocket.Conn, typ string, data any) error {
+	buffer := new(bytes.Buffer)
+	if err := gob.NewEncoder(buffer).Encode(data); err != nil {
+		return err
+	}
+	return conn.WriteJSON(Message{
+		Type: typ,
+		Data: buffer.Bytes(),
+	})
+}
+
+// saveState writes the nonce pool to the pool file and the share to the
+// keystore, if there are any
+func saveState(share *scheme.Share) error {
+	if *poolFile != "" {
+		data, err := share.Pool.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*poolFile, data, 0600); err != nil {
+			return err
+		}
+	}
+	if *keystore == "" {
+		return nil
+	}
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		return fmt.Errorf("%s is not set", passphraseEnv)
+	}
+	// Replace the keystore at once, a torn write would lose the share
+	var buf bytes.Buffer
+	if err := scheme.SaveShare(&buf, share, []byte(passphrase)); err != nil {
+		return err
+	}
+	tmp := *keystore + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, *keystore)
+}
+
+// loadShare reads the share from the keystore, nil if there is no keystore yet
+func loadShare() (*scheme.Share, error) {
+	if *keystore == "" {
+		return nil, nil
+	}
+	f, err := os.Open(*keystore)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s is not set", passphraseEnv)
+	}
+	return scheme.LoadShare(f, []byte(passphrase))
+}
+
+func transform(g scheme.Group, origin B) (scheme.B, error) {
 	b := make(scheme.B, 0)
 	for _, v := range origin {
-		E := new(bls12381.G1)
-		E.SetBytes(v.E)
-		D := new(bls12381.G1)
-		D.SetBytes(v.D)
+		E := g.NewElement()
+		if err := E.UnmarshalBinary(v.E); err != nil {
+			return nil, err
+		}
+		D := g.NewElement()
+		if err := D.UnmarshalBinary(v.D); err != nil {
+			return nil, err
+		}
 		item := scheme.BItem{
-			P: v.P,
-			E: E,
-			D: D,
+			P:     v.P,
+			E:     E,
+			D:     D,
+			Nonce: v.Nonce,
 		}
 		b = append(b, item)
 	}
+	return b, nil
+}
+
+// compress returns the compressed encoding of the element
+func compress(e group.Element) []byte {
+	b, _ := e.MarshalBinaryCompress()
 	return b
 }
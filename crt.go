@@ -1,8 +1,11 @@
 package scheme
 
 import (
-	"github.com/cloudflare/circl/ecc/bls12381"
-	"github.com/ncw/gmp"
+	"errors"
+	"fmt"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
 )
 
 // 128 bit statistical level
@@ -12,25 +15,68 @@ const LAMBDA int = 256
 // sha256
 const HASH_BITS int = 256
 
-// The order of the BLS12-381 curve
+// The order of the BLS12-381 curve, the prime p of sharings in the default group
 const BLS12381_ORDER = "73EDA753299D7D483339D80809A1D80553BDA402FFFE5BFEFFFFFFFF00000001"
 
+// CRTSharing is the state of the dealer: the public GroupParams together with
+// the secret and the remainders of all the drones. Publish Params instead,
+// and Wipe the sharing once the shares are handed out.
 type CRTSharing struct {
-	N           int          // Number of parties
-	ThresholdT1 int          // The minimum number of participants required to recover the secret.
-	ThresholdT2 int          // The minimum number of participants required for threshold signatures.
-	Thresholdt  int          // The maximum number of participants who cannot recover the secret.
-	Weight      []int        // The weight of each participant.
-	Moduli      []*gmp.Int   // The modulus of each participant.
-	Remainder   []*gmp.Int   // The remainder of each participant.
-	Secret      *gmp.Int     // The secret to be shared.
-	PMin1       *gmp.Int     // The modular product of the minimum number of participants required to recover the secret.
-	PMin2       *gmp.Int     // The modular product of the minimum number of participants required for threshold signatures.
-	PMax        *gmp.Int     // The modular product of the maximum number of participants who cannot recover the secret.
-	Pub         *bls12381.G1 // The public key
-}
-
-func NewCRTSharing(n int, t int, moduli []*gmp.Int) *CRTSharing {
+	GroupParams
+	Remainder []*bigint.Int // The remainder of each participant.
+	Secret    *bigint.Int   // The secret to be shared.
+}
+
+// Errors returned when the sharing parameters or an existing sharing are invalid.
+var (
+	ErrPartyCount         = errors.New("scheme: number of parties does not match the moduli")
+	ErrThreshold          = errors.New("scheme: threshold t must satisfy 0 < t <= n")
+	ErrInvalidModulus     = errors.New("scheme: modulus must be greater than 1")
+	ErrModuliUnsorted     = errors.New("scheme: moduli are not sorted in ascending order")
+	ErrModuliDuplicate    = errors.New("scheme: moduli contain duplicates")
+	ErrModuliNotCoprime   = errors.New("scheme: moduli are not pairwise coprime")
+	ErrPMin1Boundary      = errors.New("scheme: PMin1 must be greater than (L+1) * p")
+	ErrPMin2Boundary      = errors.New("scheme: PMin2 must be greater than S")
+	ErrSecretBoundary     = errors.New("scheme: secret must be less than or equal to (L+1) * p")
+	ErrDealers            = errors.New("scheme: number of dealers must satisfy 0 < dealers <= n")
+	ErrLengthMismatch     = errors.New("scheme: weight, moduli and remainder lengths differ")
+	ErrWeightMismatch     = errors.New("scheme: weight does not match the modulus bit length")
+	ErrThresholdMismatch  = errors.New("scheme: thresholds do not match the moduli")
+	ErrRemainderMismatch  = errors.New("scheme: remainder is not consistent with the secret")
+	ErrPublicKeyMismatch  = errors.New("scheme: public key is not consistent with the secret")
+	ErrCommitmentMismatch = errors.New("scheme: share commitment is not consistent with the remainder")
+)
+
+// NewCRTSharing creates a new sharing of a random secret among n parties.
+// It panics if the parameters are invalid, see TryNewCRTSharing.
+func NewCRTSharing(n int, t int, moduli []*bigint.Int) *CRTSharing {
+	crt, err := TryNewCRTSharing(n, t, moduli)
+	if err != nil {
+		panic(err)
+	}
+	return crt
+}
+
+// TryNewCRTSharing creates a new sharing of a random secret among n parties,
+// returning an error instead of panicking when the parameters are invalid.
+// The moduli must be sorted in ascending order and pairwise coprime.
+func TryNewCRTSharing(n int, t int, moduli []*bigint.Int) (*CRTSharing, error) {
+	return TryNewCRTSharingWithGroup(DefaultGroup, n, t, moduli)
+}
+
+// TryNewCRTSharingWithGroup is TryNewCRTSharing in the group g,
+// whose order is used as the prime p.
+func TryNewCRTSharingWithGroup(g Group, n int, t int, moduli []*bigint.Int) (*CRTSharing, error) {
+	if n <= 0 || n != len(moduli) {
+		return nil, fmt.Errorf("%w: n = %d, len(moduli) = %d", ErrPartyCount, n, len(moduli))
+	}
+	if t <= 0 || t > n {
+		return nil, fmt.Errorf("%w: t = %d, n = %d", ErrThreshold, t, n)
+	}
+	if err := checkModuli(moduli); err != nil {
+		return nil, err
+	}
+
 	// Calculate the weight of each participant
 	weight := make([]int, 0, n)
 	for _, g := range moduli {
@@ -38,77 +84,62 @@ func NewCRTSharing(n int, t int, moduli []*gmp.Int) *CRTSharing {
 	}
 
 	// Calculate the PMax
-	pMax := new(gmp.Int).SetInt64(1)
-	for i := n - t; i < n; i++ {
-		pMax.Mul(pMax, moduli[i])
-	}
+	pMax := productMax(moduli, t)
 
 	// L = 2 ** (LAMBDA + pMax.bit_length())
-	L := new(gmp.Int).SetInt64(1)
-	L.Lsh(L, uint(LAMBDA+pMax.BitLen()))
+	L := boundL(pMax)
+	defer bigint.Clear(L)
 
 	// Generate a random prime number within lambda bits
 	// p := GeneratePrime(LAMBDA)
 	//
-	// bls12381 curve order as the prime number
-	p, _ := new(gmp.Int).SetString(BLS12381_ORDER, 16)
+	// group order as the prime number
+	p := groupOrder(g)
+	defer bigint.Clear(p)
 	tmp := GeneratePrime(LAMBDA)
 
-	p0 := new(gmp.Int).Mod(tmp, p)
-	tmp.Clear()
+	p0 := new(bigint.Int).Mod(tmp, p)
+	bigint.Clear(tmp)
 
 	// leftBoundary = (L+1) * p0
-	leftBoundary := new(gmp.Int).SetInt64(1)
-	leftBoundary.Add(leftBoundary, L)
-	leftBoundary = leftBoundary.Mul(leftBoundary, p)
+	leftBoundary := recoverBound(L, p)
+	defer bigint.Clear(leftBoundary)
 
 	// Calculate the PMin
-	pMin := new(gmp.Int).SetInt64(1)
-	T := 0
-	for T < n && pMin.Cmp(leftBoundary) != 1 {
-		pMin.Mul(pMin, moduli[T])
-		T += 1
-	}
-	T1 := T
+	T1, pMin := prefixThreshold(moduli, 0, bigint.NewInt(1), leftBoundary)
 
 	// Calculate the secret
-	// S = p0 + p * L
-	S := new(gmp.Int).SetInt64(0)
+	// S = p0 + alpha * p for a uniform alpha in [0, L], a fixed alpha would
+	// make every remainder S mod m_i leak p0 mod m_i
+	S := randInt(L)
+	S.Mul(S, p)
 	S.Add(S, p0)
-	tmp = new(gmp.Int).Mul(p, L)
-	S.Add(S, tmp)
-	tmp.Clear()
+	bigint.Clear(p0)
 
 	// Make sure the secret is less than or equal to (L+1) * p0
 	// S <= (L+1) * p0
 	if S.Cmp(leftBoundary) == 1 {
-		panic("Secret must be less than or equal to (L+1) * p0")
+		bigint.Clear(S)
+		return nil, ErrSecretBoundary
 	}
 
-	// Free boundary
-	leftBoundary.Clear()
+	// rightBoundary = S
+	rightBoundary := signBound(S)
+	defer bigint.Clear(rightBoundary)
 
-	// rightBoundary = 2 ** HASH_BITS * S
-	rightBoundary := new(gmp.Int).SetInt64(1)
-	rightBoundary.Lsh(rightBoundary, uint(HASH_BITS))
-	rightBoundary.Mul(rightBoundary, S)
+	T2, pMin2 := prefixThreshold(moduli, T1, pMin, rightBoundary)
 
-	pMin2 := new(gmp.Int).Set(pMin)
-	for T < n && pMin2.Cmp(rightBoundary) != 1 {
-		pMin2.Mul(pMin2, moduli[T])
-		T++
-	}
-	T2 := T
-
-	// (L+1) * p0 < PMin < PMin2 < 2 ** HASH_BITS * S
+	// S <= (L+1) * p0 < PMin <= PMin2, the signing set of PMin1 aggregates
 	// Make sure the PMin is greater than (L+1) * p0
 	if pMin.Cmp(leftBoundary) != 1 {
-		panic("PMin must be greater than (L+1) * p")
+		bigint.Clear(S)
+		return nil, fmt.Errorf("%w: product of all %d moduli is %d bits", ErrPMin1Boundary, n, pMin.BitLen())
 	}
 
-	// Make sure the PMin2 is large than 2 ** HASH_BITS * S
+	// Make sure the PMin2 is larger than S
 	if pMin2.Cmp(rightBoundary) != 1 {
-		panic("PMin2 must be large than 2 ** HASH_BITS * S")
+		bigint.Clear(S)
+		return nil, fmt.Errorf("%w: product of all %d moduli is %d bits", ErrPMin2Boundary, n, pMin2.BitLen())
 	}
 
 	// Calculate the insecurity
@@ -116,59 +147,223 @@ func NewCRTSharing(n int, t int, moduli []*gmp.Int) *CRTSharing {
 	// insecurity := new(gmp.Rat).SetFrac(pMax, L)
 
 	// Calculate the remainder
-	remainder := make([]*gmp.Int, 0, n)
+	remainder := make([]*bigint.Int, 0, n)
 	for i := 0; i < n; i++ {
-		remainder = append(remainder, new(gmp.Int).Mod(S, moduli[i]))
+		remainder = append(remainder, new(bigint.Int).Mod(S, moduli[i]))
 	}
 
-	s := GmpToScalar(S)
-	pub := new(bls12381.G1)
-	pub.ScalarMult(s, bls12381.G1Generator())
+	s := IntToScalar(g, S)
+	pub := g.NewElement().MulGen(s)
+	zeroScalar(s)
 
 	crt := &CRTSharing{
-		N:           n,
-		ThresholdT1: T1,
-		ThresholdT2: T2,
-		Thresholdt:  t,
-		Weight:      weight,
-		Moduli:      moduli,
-		Remainder:   remainder,
-		Secret:      S,
-		PMin1:       pMin,
-		PMin2:       pMin2,
-		PMax:        pMax,
-		Pub:         pub,
+		GroupParams: GroupParams{
+			N:           n,
+			ThresholdT1: T1,
+			ThresholdT2: T2,
+			Thresholdt:  t,
+			Dealers:     1,
+			Weight:      weight,
+			Moduli:      moduli,
+			PMin1:       pMin,
+			PMin2:       pMin2,
+			PMax:        pMax,
+			Pub:         pub,
+			Group:       g,
+			Commitments: shareCommitments(g, remainder),
+		},
+		Remainder: remainder,
+		Secret:    S,
 	}
 
-	return crt
+	return crt, nil
 }
 
-// Reconstruct the secret from the shares using the Chinese Remainder Theorem
-func ReconstructSecret(moduli []*gmp.Int, remainder []*gmp.Int) *gmp.Int {
-	total := new(gmp.Int).SetInt64(0)
+// Validate re-checks the invariants of an existing sharing: the GroupParams
+// are valid, every remainder and the public key are consistent with the
+// secret, and the share commitments with the remainders. Without the secret
+// the remainders are only range checked.
+func (crt *CRTSharing) Validate() error {
+	if err := crt.GroupParams.Validate(); err != nil {
+		return err
+	}
+	if len(crt.Remainder) != crt.N {
+		return fmt.Errorf("%w: moduli = %d, remainder = %d", ErrLengthMismatch, crt.N, len(crt.Remainder))
+	}
 
-	// Calculate the product of all moduli
-	product := new(gmp.Int).SetInt64(1)
-	for _, g := range moduli {
-		product.Mul(product, g)
+	if crt.Secret == nil {
+		for i, r := range crt.Remainder {
+			if r == nil || r.Sign() < 0 || r.Cmp(crt.Moduli[i]) >= 0 {
+				return fmt.Errorf("%w: index %d", ErrRemainderMismatch, i)
+			}
+		}
+		return crt.validateCommitments()
+	}
+
+	// S <= (L+1) * p and S < PMin2
+	left := crt.RecoveryBound()
+	defer bigint.Clear(left)
+	if crt.Secret.Sign() < 0 || crt.Secret.Cmp(left) == 1 {
+		return ErrSecretBoundary
+	}
+	right := signBound(crt.Secret)
+	defer bigint.Clear(right)
+	if crt.PMin2.Cmp(right) != 1 {
+		return ErrPMin2Boundary
 	}
 
-	tmp := new(gmp.Int)
+	r := new(bigint.Int)
+	defer bigint.Clear(r)
+	for i, g := range crt.Moduli {
+		r.Mod(crt.Secret, g)
+		if crt.Remainder[i] == nil || r.Cmp(crt.Remainder[i]) != 0 {
+			return fmt.Errorf("%w: index %d", ErrRemainderMismatch, i)
+		}
+	}
+
+	pub := crt.Group.NewElement().MulGen(IntToScalar(crt.Group, crt.Secret))
+	if !pub.IsEqual(crt.Pub) {
+		return ErrPublicKeyMismatch
+	}
+	return crt.validateCommitments()
+}
+
+// validateCommitments checks the share commitments against the remainders if
+// there are any, GroupParams.Validate checked their number and group
+func (crt *CRTSharing) validateCommitments() error {
+	for i, Y := range crt.Commitments {
+		if !Y.IsEqual(shareCommitment(crt.Group, crt.Remainder[i])) {
+			return fmt.Errorf("%w: index %d", ErrCommitmentMismatch, i)
+		}
+	}
+	return nil
+}
+
+// ActiveModuli returns the moduli that are not revoked, ThresholdT1 and
+// ThresholdT2 count the smallest of them.
+func (gp *GroupParams) ActiveModuli() []*bigint.Int {
+	return activeModuli(gp.Moduli, gp.Revoked)
+}
+
+func activeModuli(moduli []*bigint.Int, revoked []bool) []*bigint.Int {
+	if revoked == nil {
+		return moduli
+	}
+	active := make([]*bigint.Int, 0, len(moduli))
+	for i, m := range moduli {
+		if !revoked[i] {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+// shareCommitment returns the commitment Y = r * G to the remainder r
+func shareCommitment(g Group, r *bigint.Int) group.Element {
+	s := IntToScalar(g, r)
+	defer zeroScalar(s)
+	return g.NewElement().MulGen(s)
+}
+
+// shareCommitments returns the commitments to all the remainders
+func shareCommitments(g Group, remainder []*bigint.Int) []group.Element {
+	Y := make([]group.Element, 0, len(remainder))
+	for _, r := range remainder {
+		Y = append(Y, shareCommitment(g, r))
+	}
+	return Y
+}
+
+// checkModuli makes sure the moduli are greater than 1, strictly ascending and
+// pairwise coprime.
+func checkModuli(moduli []*bigint.Int) error {
+	one := bigint.NewInt(1)
+	for i, g := range moduli {
+		if g == nil || g.Cmp(one) != 1 {
+			return fmt.Errorf("%w: index %d", ErrInvalidModulus, i)
+		}
+		if i == 0 {
+			continue
+		}
+		switch moduli[i-1].Cmp(g) {
+		case 1:
+			return fmt.Errorf("%w: index %d", ErrModuliUnsorted, i)
+		case 0:
+			return fmt.Errorf("%w: index %d", ErrModuliDuplicate, i)
+		}
+	}
+
+	gcd := new(bigint.Int)
+	defer bigint.Clear(gcd)
 	for i := 0; i < len(moduli); i++ {
-		// product / moduli[i]
-		p := new(gmp.Int).Div(product, moduli[i])
-		// p^-1 mod moduli[i]
-		pInv := new(gmp.Int).ModInverse(p, moduli[i])
-		// (p * p^-1 * remainder[i]) % product
-		tmp.Mul(p, pInv)
-		tmp.Mul(tmp, remainder[i])
-		tmp.Mod(tmp, product)
-		// total += (p * p^-1 * remainder[i]) % product
-		total.Add(total, tmp)
-	}
-	total.Mod(total, product)
-	// Free the memory
-	tmp.Clear()
-	product.Clear()
-	return total
+		for j := i + 1; j < len(moduli); j++ {
+			gcd.GCD(nil, nil, moduli[i], moduli[j])
+			if gcd.Cmp(one) != 0 {
+				return fmt.Errorf("%w: moduli[%d] and moduli[%d]", ErrModuliNotCoprime, i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// prefixThreshold extends the product pMin of the first T moduli until it is
+// greater than bound or all the moduli are used, and returns the new T and product.
+func prefixThreshold(moduli []*bigint.Int, T int, pMin, bound *bigint.Int) (int, *bigint.Int) {
+	p := new(bigint.Int).Set(pMin)
+	for T < len(moduli) && p.Cmp(bound) != 1 {
+		p.Mul(p, moduli[T])
+		T++
+	}
+	return T, p
+}
+
+// product returns the product of the moduli
+func product(moduli []*bigint.Int) *bigint.Int {
+	p := new(bigint.Int).SetInt64(1)
+	for _, g := range moduli {
+		p.Mul(p, g)
+	}
+	return p
+}
+
+// productMax returns the product of the t largest moduli
+func productMax(moduli []*bigint.Int, t int) *bigint.Int {
+	return product(moduli[len(moduli)-t:])
+}
+
+// boundL returns L = 2 ** (LAMBDA + pMax.bit_length())
+func boundL(pMax *bigint.Int) *bigint.Int {
+	L := new(bigint.Int).SetInt64(1)
+	return L.Lsh(L, uint(LAMBDA+pMax.BitLen()))
+}
+
+// recoverBound returns (L+1) * p
+func recoverBound(L, p *bigint.Int) *bigint.Int {
+	b := new(bigint.Int).SetInt64(1)
+	b.Add(b, L)
+	return b.Mul(b, p)
+}
+
+// signBound returns S: the partial signatures are reduced modulo the group
+// order, their sum determines S once the product of the signers exceeds it
+func signBound(S *bigint.Int) *bigint.Int {
+	return new(bigint.Int).Set(S)
+}
+
+// Reconstruct the secret from the shares using the Chinese Remainder Theorem.
+//
+// ReconstructSecret returns nil if the moduli are not valid and pairwise
+// coprime or the lengths differ, where it used to return a meaningless
+// number. Callers must check for nil, NewCRTContext and Reconstruct return
+// the reason instead.
+func ReconstructSecret(moduli []*bigint.Int, remainder []*bigint.Int) *bigint.Int {
+	ctx, err := NewCRTContext(moduli)
+	if err != nil {
+		return nil
+	}
+	x, err := ctx.Reconstruct(remainder)
+	if err != nil {
+		return nil
+	}
+	return x
 }
@@ -0,0 +1,213 @@
+package scheme
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+)
+
+// CRTContext holds the product tree of a set of pairwise coprime moduli
+// m_1, ..., m_n with M = m_1 * ... * m_n, and for every m_i the cofactor
+// M / m_i reduced mod m_i and its inverse. A remainder tree gives all of
+// them with O(log n) multiplications and divisions of numbers of the size
+// of M, instead of one division and inversion of M per modulus.
+type CRTContext struct {
+	Moduli   []*bigint.Int
+	tree     [][]*bigint.Int // tree[0] are the moduli, tree[k][j] = tree[k-1][2j] * tree[k-1][2j+1]
+	inverses []*bigint.Int   // (M / m_i)^-1 mod m_i
+}
+
+// NewCRTContext builds the context of the moduli, which must be pairwise coprime
+func NewCRTContext(moduli []*bigint.Int) (*CRTContext, error) {
+	if len(moduli) == 0 {
+		return nil, fmt.Errorf("%w: no moduli", ErrPartyCount)
+	}
+	one := bigint.NewInt(1)
+	for i, m := range moduli {
+		if m == nil || m.Cmp(one) != 1 {
+			return nil, fmt.Errorf("%w: index %d", ErrInvalidModulus, i)
+		}
+	}
+	ctx := &CRTContext{Moduli: slices.Clone(moduli), tree: productTree(moduli)}
+
+	// M mod m_i^2 = (M / m_i mod m_i) * m_i, walking the squares down the tree
+	rem := []*bigint.Int{new(bigint.Int).Set(ctx.Product())}
+	sq := new(bigint.Int)
+	defer bigint.Clear(sq)
+	for k := len(ctx.tree) - 2; k >= 0; k-- {
+		level := ctx.tree[k]
+		next := make([]*bigint.Int, len(level))
+		for j, node := range level {
+			sq.Mul(node, node)
+			next[j] = new(bigint.Int).Mod(rem[j/2], sq)
+		}
+		for _, r := range rem {
+			bigint.Clear(r)
+		}
+		rem = next
+	}
+
+	ctx.inverses = make([]*bigint.Int, len(moduli))
+	for i, m := range ctx.Moduli {
+		cofactor := rem[i].Div(rem[i], m)
+		inv := modInverse(cofactor, m)
+		bigint.Clear(cofactor)
+		if inv == nil {
+			return nil, fmt.Errorf("%w: index %d", ErrModuliNotCoprime, i)
+		}
+		ctx.inverses[i] = inv
+	}
+	return ctx, nil
+}
+
+// modInverse returns x^-1 mod m, or nil if x and m are not coprime
+func modInverse(x, m *bigint.Int) *bigint.Int {
+	inv := new(bigint.Int)
+	// math/big returns nil and gmp leaves inv undefined if x has no inverse
+	if inv.ModInverse(x, m) == nil {
+		return nil
+	}
+	check := new(bigint.Int).Mul(x, inv)
+	defer bigint.Clear(check)
+	if check.Mod(check, m).Cmp(bigint.NewInt(1)) != 0 {
+		bigint.Clear(inv)
+		return nil
+	}
+	return inv
+}
+
+// productTree returns the levels of the product tree of the moduli from the
+// leaves up to the root, an odd node is carried up unchanged
+func productTree(moduli []*bigint.Int) [][]*bigint.Int {
+	level := slices.Clone(moduli)
+	tree := [][]*bigint.Int{level}
+	for len(level) > 1 {
+		next := make([]*bigint.Int, 0, (len(level)+1)/2)
+		for j := 0; j+1 < len(level); j += 2 {
+			next = append(next, new(bigint.Int).Mul(level[j], level[j+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		tree = append(tree, next)
+		level = next
+	}
+	return tree
+}
+
+// Len returns the number of moduli
+func (ctx *CRTContext) Len() int {
+	return len(ctx.Moduli)
+}
+
+// Product returns M, the product of all the moduli
+func (ctx *CRTContext) Product() *bigint.Int {
+	return ctx.tree[len(ctx.tree)-1][0]
+}
+
+// Index returns the index of the modulus, or -1 if it is not part of the context
+func (ctx *CRTContext) Index(modulus *bigint.Int) int {
+	return slices.IndexFunc(ctx.Moduli, func(m *bigint.Int) bool { return m.Cmp(modulus) == 0 })
+}
+
+// Inverse returns (M / m_i)^-1 mod m_i
+func (ctx *CRTContext) Inverse(i int) *bigint.Int {
+	return new(bigint.Int).Set(ctx.inverses[i])
+}
+
+// Lambda returns the CRT coefficient of the i-th modulus
+// lambda_i = (M / m_i) * ((M / m_i)^-1 mod m_i)
+func (ctx *CRTContext) Lambda(i int) *bigint.Int {
+	lambda := new(bigint.Int).Div(ctx.Product(), ctx.Moduli[i])
+	return lambda.Mul(lambda, ctx.inverses[i])
+}
+
+// share returns u_i = lambda_i * r mod M = (M / m_i) * (inv_i * r mod m_i),
+// the share of the i-th modulus in sum(u_j) = x + carry * M with carry < Len
+func (ctx *CRTContext) share(i int, r *bigint.Int) *bigint.Int {
+	q := new(bigint.Int).Div(ctx.Product(), ctx.Moduli[i])
+	defer bigint.Clear(q)
+	return cofactorShare(q, ctx.inverses[i], ctx.Moduli[i], r)
+}
+
+// cofactorShare returns q * (inv * r mod m), where q is the product of the
+// other moduli and inv its inverse mod m
+func cofactorShare(q, inv, m, r *bigint.Int) *bigint.Int {
+	u := new(bigint.Int).Mul(r, inv)
+	u.Mod(u, m)
+	return u.Mul(u, q)
+}
+
+// Reconstruct returns the x < M with x = remainder[i] mod m_i. The terms
+// r_i * inv_i are combined up the product tree, a node adds the value of
+// its left child times the product of its right child and vice versa.
+func (ctx *CRTContext) Reconstruct(remainder []*bigint.Int) (*bigint.Int, error) {
+	if len(remainder) != ctx.Len() {
+		return nil, fmt.Errorf("%w: moduli = %d, remainder = %d", ErrLengthMismatch, ctx.Len(), len(remainder))
+	}
+	values := make([]*bigint.Int, ctx.Len())
+	for i, m := range ctx.Moduli {
+		v := new(bigint.Int).Mul(remainder[i], ctx.inverses[i])
+		values[i] = v.Mod(v, m)
+	}
+	tmp := new(bigint.Int)
+	defer bigint.Clear(tmp)
+	for k := 0; k+1 < len(ctx.tree); k++ {
+		level := ctx.tree[k]
+		next := make([]*bigint.Int, 0, (len(values)+1)/2)
+		for j := 0; j+1 < len(values); j += 2 {
+			v := values[j].Mul(values[j], level[j+1])
+			tmp.Mul(values[j+1], level[j])
+			next = append(next, v.Add(v, tmp))
+			bigint.Clear(values[j+1])
+		}
+		if len(values)%2 == 1 {
+			next = append(next, values[len(values)-1])
+		}
+		values = next
+	}
+	return values[0].Mod(values[0], ctx.Product()), nil
+}
+
+// lambda is the CRT coefficient of one modulus m_i of a set, which takes one
+// product and one inversion instead of the context of the whole set
+type lambda struct {
+	moduli   []*bigint.Int // The moduli of the set
+	i        int           // Index of m_i
+	cofactor *bigint.Int   // M / m_i, the product of the other moduli
+	inverse  *bigint.Int   // (M / m_i)^-1 mod m_i
+}
+
+// lambdaOf returns the coefficient of the i-th modulus of B. Only m_i is
+// checked to be coprime to the others, the aggregation fails if the other
+// moduli are not.
+func lambdaOf(B B, i int) (*lambda, error) {
+	l := &lambda{moduli: make([]*bigint.Int, 0, len(B)), i: i, cofactor: bigint.NewInt(1)}
+	one := bigint.NewInt(1)
+	for j, item := range B {
+		if item.P == nil || item.P.Cmp(one) != 1 {
+			return nil, fmt.Errorf("%w: index %d", ErrInvalidModulus, j)
+		}
+		l.moduli = append(l.moduli, item.P)
+		if j != i {
+			l.cofactor.Mul(l.cofactor, item.P)
+		}
+	}
+	if l.inverse = modInverse(l.cofactor, l.moduli[i]); l.inverse == nil {
+		return nil, fmt.Errorf("%w: index %d", ErrModuliNotCoprime, i)
+	}
+	return l, nil
+}
+
+// share returns u_i = lambda_i * r mod M, see CRTContext.share
+func (l *lambda) share(r *bigint.Int) *bigint.Int {
+	return cofactorShare(l.cofactor, l.inverse, l.moduli[l.i], r)
+}
+
+// matches reports whether B has the moduli of the coefficient in the same order
+func (l *lambda) matches(B B, i int) bool {
+	return l != nil && l.i == i && slices.EqualFunc(l.moduli, B, func(m *bigint.Int, item BItem) bool {
+		return m.Cmp(item.P) == 0
+	})
+}
@@ -0,0 +1,135 @@
+package scheme_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRTContext(t *testing.T) {
+	once()
+	for _, n := range []int{1, 2, 3, 5, 8, 13} {
+		mod := moduli[:n]
+		ctx, err := scheme.NewCRTContext(mod)
+		assert.NoError(t, err)
+
+		M := new(bigint.Int).SetInt64(1)
+		for _, m := range mod {
+			M.Mul(M, m)
+		}
+		assert.Equal(t, 0, ctx.Product().Cmp(M))
+
+		// lambda_i = 1 mod m_i and 0 mod m_j
+		for i := range mod {
+			lambda := ctx.Lambda(i)
+			for j, m := range mod {
+				r := new(bigint.Int).Mod(lambda, m)
+				if i == j {
+					assert.Equal(t, 0, r.Cmp(bigint.NewInt(1)))
+				} else {
+					assert.Equal(t, 0, r.Sign())
+				}
+			}
+			assert.Equal(t, i, ctx.Index(mod[i]))
+		}
+
+		x := randBelow(M)
+		remainder := make([]*bigint.Int, 0, n)
+		for _, m := range mod {
+			remainder = append(remainder, new(bigint.Int).Mod(x, m))
+		}
+		y, err := ctx.Reconstruct(remainder)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, y.Cmp(x))
+		_, err = ctx.Reconstruct(remainder[1:])
+		assert.ErrorIs(t, err, scheme.ErrLengthMismatch)
+	}
+
+	T1 := crt.ThresholdT1
+	assert.Equal(t, 0, scheme.ReconstructSecret(moduli[:T1], crt.Remainder[:T1]).Cmp(crt.Secret))
+
+	_, err := scheme.NewCRTContext(nil)
+	assert.ErrorIs(t, err, scheme.ErrPartyCount)
+	_, err = scheme.NewCRTContext([]*bigint.Int{bigint.NewInt(15), bigint.NewInt(7), bigint.NewInt(21)})
+	assert.ErrorIs(t, err, scheme.ErrModuliNotCoprime)
+	_, err = scheme.NewCRTContext([]*bigint.Int{bigint.NewInt(7), bigint.NewInt(1)})
+	assert.ErrorIs(t, err, scheme.ErrInvalidModulus)
+	assert.Nil(t, scheme.ReconstructSecret([]*bigint.Int{bigint.NewInt(7), bigint.NewInt(7)}, []*bigint.Int{bigint.NewInt(1), bigint.NewInt(1)}))
+	assert.Nil(t, scheme.ReconstructSecret(moduli[:T1], crt.Remainder[:T1-1]))
+}
+
+// randBelow returns a random number in [0, m)
+func randBelow(m *bigint.Int) *bigint.Int {
+	x, err := rand.Int(rand.Reader, new(big.Int).SetBytes(m.Bytes()))
+	if err != nil {
+		panic(err)
+	}
+	return new(bigint.Int).SetBytes(x.Bytes())
+}
+
+// swarm returns n distinct primes of mixed weight and random remainders,
+// GenerateNumber narrows the range of the primes and is too slow for large n
+func swarm(n int) ([]*bigint.Int, []*bigint.Int) {
+	weights := []int{16, 128, 512}
+	seen := make(map[string]bool)
+	mod := make([]*bigint.Int, 0, n)
+	remainder := make([]*bigint.Int, 0, n)
+	for len(mod) < n {
+		m := scheme.GeneratePrime(weights[len(mod)%len(weights)])
+		if seen[m.String()] {
+			continue
+		}
+		seen[m.String()] = true
+		mod = append(mod, m)
+		remainder = append(remainder, randBelow(m))
+	}
+	return mod, remainder
+}
+
+func BenchmarkReconstructSecret(b *testing.B) {
+	for _, n := range []int{256, 1024} {
+		mod, remainder := swarm(n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scheme.ReconstructSecret(mod, remainder)
+			}
+		})
+	}
+}
+
+func BenchmarkSignSwarm(b *testing.B) {
+	g := scheme.DefaultGroup
+	for _, n := range []int{256, 1024} {
+		mod, remainder := swarm(n)
+		E := make([]group.Element, 0, n)
+		D := make([]group.Element, 0, n)
+		for i := 0; i < n; i++ {
+			E = append(E, g.NewElement().MulGen(g.RandomScalar(rand.Reader)))
+			D = append(D, g.NewElement().MulGen(g.RandomScalar(rand.Reader)))
+		}
+		B := scheme.NewB(mod, E, D)
+		e, d := g.RandomScalar(rand.Reader), g.RandomScalar(rand.Reader)
+		pub := g.NewElement().MulGen(g.RandomScalar(rand.Reader))
+		m := "Hello World"
+
+		// A fresh signer builds the context of B, a signer that signed before reuses it
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scheme.NewSigner(e, d, remainder[0], pub, B[0]).Sign(m, pub, B)
+			}
+		})
+		signer := scheme.NewSigner(e, d, remainder[0], pub, B[0])
+		signer.Sign(m, pub, B)
+		b.Run(fmt.Sprintf("n=%d/reuse", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				signer.Sign(m, pub, B)
+			}
+		})
+	}
+}
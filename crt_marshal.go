@@ -0,0 +1,437 @@
+package scheme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+// Magic bytes at the start of a binary encoded CRTSharing
+const crtMagic = "CRTS"
+
+// Current version of the CRTSharing encodings.
+// Version 3 adds the revoked moduli, version 2 the group,
+// version 1 sharings are in BLS12-381 G1.
+const CRTSharingVersion byte = 3
+
+var (
+	ErrEncoding        = errors.New("scheme: malformed encoding")
+	ErrEncodingMagic   = errors.New("scheme: unknown encoding magic")
+	ErrEncodingVersion = errors.New("scheme: unsupported encoding version")
+)
+
+// MarshalBinary encodes the sharing as
+//
+//	"CRTS" || version || group || N || T1 || T2 || t || weight[N] || moduli[N] || remainder[N] ||
+//	PMin1 || PMin2 || PMax || hasSecret || secret? || pub || revoked
+//
+// where the thresholds and weights are uvarints and every integer is a
+// uvarint length followed by its big-endian bytes. revoked is the uvarint count
+// and the ascending uvarint indices of the revoked moduli. The share commitments are
+// not encoded, they are recomputed from the remainders when decoding.
+func (crt *CRTSharing) MarshalBinary() ([]byte, error) {
+	if len(crt.Weight) != crt.N || len(crt.Moduli) != crt.N || len(crt.Remainder) != crt.N ||
+		(crt.Revoked != nil && len(crt.Revoked) != crt.N) {
+		return nil, ErrLengthMismatch
+	}
+	if crt.PMin1 == nil || crt.PMin2 == nil || crt.PMax == nil || !sameGroup(crt.Group, crt.Pub) {
+		return nil, fmt.Errorf("%w: missing field", ErrEncoding)
+	}
+
+	buf := make([]byte, 0, 64+crt.N*2*(crt.PMax.BitLen()/8+2))
+	buf = append(buf, crtMagic...)
+	buf = append(buf, CRTSharingVersion, byte(crt.Group.ID()))
+	buf = binary.AppendUvarint(buf, uint64(crt.N))
+	buf = binary.AppendUvarint(buf, uint64(crt.ThresholdT1))
+	buf = binary.AppendUvarint(buf, uint64(crt.ThresholdT2))
+	buf = binary.AppendUvarint(buf, uint64(crt.Thresholdt))
+	for _, w := range crt.Weight {
+		buf = binary.AppendUvarint(buf, uint64(w))
+	}
+	for _, g := range crt.Moduli {
+		buf = appendInt(buf, g)
+	}
+	for _, r := range crt.Remainder {
+		buf = appendInt(buf, r)
+	}
+	buf = appendInt(buf, crt.PMin1)
+	buf = appendInt(buf, crt.PMin2)
+	buf = appendInt(buf, crt.PMax)
+	if crt.Secret != nil {
+		buf = append(buf, 1)
+		buf = appendInt(buf, crt.Secret)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = append(buf, elementBytes(crt.Pub)...)
+	return appendRevoked(buf, crt.Revoked), nil
+}
+
+// UnmarshalBinary decodes a sharing produced by MarshalBinary.
+// It only checks the structure of the encoding, call Validate to check the
+// invariants of the decoded sharing.
+func (crt *CRTSharing) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(crtMagic)) {
+		return ErrEncodingMagic
+	}
+	r := &reader{buf: data[len(crtMagic):]}
+	var c CRTSharing
+	v := r.byte()
+	switch {
+	case r.err != nil:
+		return r.err
+	case v == 1:
+		c.Group = BLS12381G1
+	case v == 2 || v == CRTSharingVersion:
+		g, err := GroupByID(GroupID(r.byte()))
+		if err != nil {
+			return err
+		}
+		c.Group = g
+	default:
+		return fmt.Errorf("%w: %d", ErrEncodingVersion, v)
+	}
+
+	c.N = r.int()
+	c.ThresholdT1 = r.int()
+	c.ThresholdT2 = r.int()
+	c.Thresholdt = r.int()
+	c.Dealers = 1
+	// Every party needs at least two bytes, reject absurd lengths early
+	if r.err == nil && c.N > len(r.buf)/2 {
+		return fmt.Errorf("%w: N = %d", ErrEncoding, c.N)
+	}
+	c.Weight = make([]int, 0, c.N)
+	for i := 0; i < c.N; i++ {
+		c.Weight = append(c.Weight, r.int())
+	}
+	c.Moduli = make([]*bigint.Int, 0, c.N)
+	for i := 0; i < c.N; i++ {
+		c.Moduli = append(c.Moduli, r.bigInt())
+	}
+	c.Remainder = make([]*bigint.Int, 0, c.N)
+	for i := 0; i < c.N; i++ {
+		c.Remainder = append(c.Remainder, r.bigInt())
+	}
+	c.PMin1 = r.bigInt()
+	c.PMin2 = r.bigInt()
+	c.PMax = r.bigInt()
+	switch r.byte() {
+	case 0:
+	case 1:
+		c.Secret = r.bigInt()
+	default:
+		r.fail()
+	}
+	c.Pub = r.element(c.Group)
+	if v == CRTSharingVersion {
+		c.Revoked = r.revoked(c.N)
+	}
+	if r.err == nil && len(r.buf) != 0 {
+		r.fail()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	c.Commitments = shareCommitments(c.Group, c.Remainder)
+	*crt = c
+	return nil
+}
+
+// crtSharingJSON is the JSON form of CRTSharing, integers are hex encoded
+type crtSharingJSON struct {
+	Version     byte     `json:"version"`
+	Group       GroupID  `json:"group,omitempty"`
+	N           int      `json:"n"`
+	ThresholdT1 int      `json:"threshold_t1"`
+	ThresholdT2 int      `json:"threshold_t2"`
+	Thresholdt  int      `json:"threshold_t"`
+	Weight      []int    `json:"weight"`
+	Moduli      []string `json:"moduli"`
+	Remainder   []string `json:"remainder"`
+	Secret      *string  `json:"secret,omitempty"`
+	PMin1       string   `json:"pmin1"`
+	PMin2       string   `json:"pmin2"`
+	PMax        string   `json:"pmax"`
+	Pub         string   `json:"pub"`
+	Revoked     []int    `json:"revoked,omitempty"`
+}
+
+// MarshalJSON encodes the sharing as a JSON object with a version field,
+// integers and the compressed public key are hex encoded.
+func (crt *CRTSharing) MarshalJSON() ([]byte, error) {
+	if len(crt.Weight) != crt.N || len(crt.Moduli) != crt.N || len(crt.Remainder) != crt.N ||
+		(crt.Revoked != nil && len(crt.Revoked) != crt.N) {
+		return nil, ErrLengthMismatch
+	}
+	if crt.PMin1 == nil || crt.PMin2 == nil || crt.PMax == nil || !sameGroup(crt.Group, crt.Pub) {
+		return nil, fmt.Errorf("%w: missing field", ErrEncoding)
+	}
+	v := crtSharingJSON{
+		Version:     CRTSharingVersion,
+		Group:       crt.Group.ID(),
+		N:           crt.N,
+		ThresholdT1: crt.ThresholdT1,
+		ThresholdT2: crt.ThresholdT2,
+		Thresholdt:  crt.Thresholdt,
+		Weight:      crt.Weight,
+		Moduli:      hexInts(crt.Moduli),
+		Remainder:   hexInts(crt.Remainder),
+		PMin1:       hex.EncodeToString(crt.PMin1.Bytes()),
+		PMin2:       hex.EncodeToString(crt.PMin2.Bytes()),
+		PMax:        hex.EncodeToString(crt.PMax.Bytes()),
+		Pub:         hex.EncodeToString(elementBytes(crt.Pub)),
+		Revoked:     revokedIndices(crt.Revoked),
+	}
+	if crt.Secret != nil {
+		s := hex.EncodeToString(crt.Secret.Bytes())
+		v.Secret = &s
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a sharing produced by MarshalJSON.
+// Like UnmarshalBinary it does not call Validate.
+func (crt *CRTSharing) UnmarshalJSON(data []byte) error {
+	var v crtSharingJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var g Group
+	switch v.Version {
+	case 1:
+		g = BLS12381G1
+	case 2, CRTSharingVersion:
+		var err error
+		if g, err = GroupByID(v.Group); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %d", ErrEncodingVersion, v.Version)
+	}
+	if len(v.Weight) != v.N || len(v.Moduli) != v.N || len(v.Remainder) != v.N {
+		return ErrLengthMismatch
+	}
+
+	var err error
+	c := CRTSharing{GroupParams: GroupParams{
+		N:           v.N,
+		ThresholdT1: v.ThresholdT1,
+		ThresholdT2: v.ThresholdT2,
+		Thresholdt:  v.Thresholdt,
+		Dealers:     1,
+		Weight:      v.Weight,
+		Group:       g,
+	}}
+	if c.Moduli, err = unhexInts(v.Moduli); err != nil {
+		return err
+	}
+	if c.Remainder, err = unhexInts(v.Remainder); err != nil {
+		return err
+	}
+	if c.PMin1, err = unhexInt(v.PMin1); err != nil {
+		return err
+	}
+	if c.PMin2, err = unhexInt(v.PMin2); err != nil {
+		return err
+	}
+	if c.PMax, err = unhexInt(v.PMax); err != nil {
+		return err
+	}
+	if v.Secret != nil {
+		if c.Secret, err = unhexInt(*v.Secret); err != nil {
+			return err
+		}
+	}
+	pub, err := hex.DecodeString(v.Pub)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+	c.Pub = g.NewElement()
+	if err := c.Pub.UnmarshalBinary(pub); err != nil || len(pub) != int(g.Params().CompressedElementLength) {
+		return fmt.Errorf("%w: invalid public key", ErrEncoding)
+	}
+	if c.Revoked, err = revokedFlags(c.N, v.Revoked); err != nil {
+		return err
+	}
+	c.Commitments = shareCommitments(g, c.Remainder)
+	*crt = c
+	return nil
+}
+
+// appendRevoked appends the uvarint count and the ascending uvarint indices
+// of the revoked moduli
+func appendRevoked(buf []byte, revoked []bool) []byte {
+	indices := revokedIndices(revoked)
+	buf = binary.AppendUvarint(buf, uint64(len(indices)))
+	for _, i := range indices {
+		buf = binary.AppendUvarint(buf, uint64(i))
+	}
+	return buf
+}
+
+// revokedIndices returns the ascending indices of the revoked moduli
+func revokedIndices(revoked []bool) []int {
+	res := make([]int, 0)
+	for i, r := range revoked {
+		if r {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// revokedFlags is the inverse of revokedIndices for n moduli,
+// it returns nil if no modulus is revoked
+func revokedFlags(n int, indices []int) ([]bool, error) {
+	if len(indices) == 0 {
+		return nil, nil
+	}
+	revoked := make([]bool, n)
+	for k, i := range indices {
+		if i < 0 || i >= n || (k > 0 && i <= indices[k-1]) {
+			return nil, fmt.Errorf("%w: revoked index %d", ErrEncoding, i)
+		}
+		revoked[i] = true
+	}
+	return revoked, nil
+}
+
+// appendInt appends the uvarint length and big-endian bytes of a non-negative integer
+func appendInt(buf []byte, x *bigint.Int) []byte {
+	b := x.Bytes()
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func hexInts(xs []*bigint.Int) []string {
+	res := make([]string, 0, len(xs))
+	for _, x := range xs {
+		res = append(res, hex.EncodeToString(x.Bytes()))
+	}
+	return res
+}
+
+func unhexInt(s string) (*bigint.Int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+	return new(bigint.Int).SetBytes(b), nil
+}
+
+func unhexInts(ss []string) ([]*bigint.Int, error) {
+	res := make([]*bigint.Int, 0, len(ss))
+	for _, s := range ss {
+		x, err := unhexInt(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, x)
+	}
+	return res, nil
+}
+
+// reader decodes the binary encodings, the first error is sticky
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = ErrEncoding
+	}
+	r.buf = nil
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || len(r.buf) < n {
+		r.fail()
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return x
+}
+
+func (r *reader) int() int {
+	x := r.uvarint()
+	if x > math.MaxInt32 {
+		r.fail()
+		return 0
+	}
+	return int(x)
+}
+
+func (r *reader) bigInt() *bigint.Int {
+	n := r.uvarint()
+	if n > uint64(len(r.buf)) {
+		r.fail()
+		return new(bigint.Int)
+	}
+	return new(bigint.Int).SetBytes(r.bytes(int(n)))
+}
+
+// revoked reads the revoked moduli of n parties written by appendRevoked
+func (r *reader) revoked(n int) []bool {
+	k := r.int()
+	if r.err != nil || k > len(r.buf) {
+		r.fail()
+		return nil
+	}
+	indices := make([]int, 0, k)
+	for i := 0; i < k && r.err == nil; i++ {
+		indices = append(indices, r.int())
+	}
+	if r.err != nil {
+		return nil
+	}
+	revoked, err := revokedFlags(n, indices)
+	if err != nil {
+		r.err, r.buf = err, nil
+	}
+	return revoked
+}
+
+// element reads a compressed element of the group g
+func (r *reader) element(g Group) group.Element {
+	b := r.bytes(int(g.Params().CompressedElementLength))
+	e := g.NewElement()
+	if r.err == nil {
+		if err := e.UnmarshalBinary(b); err != nil {
+			r.fail()
+		}
+	}
+	return e
+}
@@ -0,0 +1,58 @@
+package scheme_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRTSharingBinary(t *testing.T) {
+	once()
+	data, err := crt.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := new(scheme.CRTSharing)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, decoded.Validate())
+	assert.Equal(t, crt.Secret.String(), decoded.Secret.String())
+	assert.True(t, crt.Pub.IsEqual(decoded.Pub))
+
+	again, err := decoded.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	// Truncated, trailing and unknown version encodings are rejected
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[1:]), scheme.ErrEncodingMagic)
+	bad := append([]byte{}, data...)
+	bad[4] = 0xff
+	assert.ErrorIs(t, decoded.UnmarshalBinary(bad), scheme.ErrEncodingVersion)
+
+	// Version 2 has no revoked moduli
+	v2 := append([]byte{}, data[:len(data)-1]...)
+	v2[4] = 2
+	assert.NoError(t, decoded.UnmarshalBinary(v2))
+	assert.NoError(t, decoded.Validate())
+}
+
+func TestCRTSharingJSON(t *testing.T) {
+	once()
+	data, err := json.Marshal(crt)
+	assert.NoError(t, err)
+
+	decoded := new(scheme.CRTSharing)
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	assert.NoError(t, decoded.Validate())
+
+	again, err := json.Marshal(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	// Both encodings describe the same sharing
+	b1, _ := crt.MarshalBinary()
+	b2, _ := decoded.MarshalBinary()
+	assert.Equal(t, b1, b2)
+}
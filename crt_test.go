@@ -1,25 +1,27 @@
 package scheme_test
 
 import (
+	"crypto/rand"
 	"flag"
+	"slices"
 	"sync"
 	"testing"
 
 	"github.com/52funny/scheme"
-	"github.com/cloudflare/circl/ecc/bls12381"
-	"github.com/ncw/gmp"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
 	"github.com/stretchr/testify/assert"
 )
 
 var N = flag.Int("num", 50, "number of shares")
 var T = flag.Int("threshold", 20, "threshold")
 
-var moduli []*gmp.Int
+var moduli []*bigint.Int
 var crt *scheme.CRTSharing
-var ei []*bls12381.Scalar
-var di []*bls12381.Scalar
-var Ei []*bls12381.G1
-var Di []*bls12381.G1
+var ei []group.Scalar
+var di []group.Scalar
+var Ei []group.Element
+var Di []group.Element
 
 // Setup the parameters
 func preparation() {
@@ -29,19 +31,16 @@ func preparation() {
 	moduli = scheme.GenerateNumber(weightOpts, n)
 	crt = scheme.NewCRTSharing(n, t-4, moduli)
 
+	g := crt.Group
 	for i := 0; i < n; i++ {
-		ei = append(ei, scheme.GenerateScalar())
-		di = append(di, scheme.GenerateScalar())
+		ei = append(ei, g.RandomScalar(rand.Reader))
+		di = append(di, g.RandomScalar(rand.Reader))
 
 		// E = ei * G
-		E := new(bls12381.G1)
-		E.ScalarMult(ei[i], bls12381.G1Generator())
-		Ei = append(Ei, E)
+		Ei = append(Ei, g.NewElement().MulGen(ei[i]))
 
 		// D = di * G
-		D := new(bls12381.G1)
-		D.ScalarMult(di[i], bls12381.G1Generator())
-		Di = append(Di, D)
+		Di = append(Di, g.NewElement().MulGen(di[i]))
 	}
 }
 
@@ -58,20 +57,33 @@ func TestCRT(t *testing.T) {
 
 	m := "Hello World"
 	signers := make([]*scheme.Signer, 0, T)
-	signs := make([]*gmp.Int, 0, T)
-	Rs := make([]*bls12381.G1, 0, T)
+	signs := make([]*bigint.Int, 0, T)
+	Rs := make([]group.Element, 0, T)
 
-	P := new(gmp.Int).SetInt64(1)
+	P := new(bigint.Int).SetInt64(1)
 	for i := 0; i < T; i++ {
 		signers = append(signers, scheme.NewSigner(ei[i], di[i], remainder[i], crt.Pub, B[i]))
-		s, R := signers[i].Sign(m, crt.Pub, B)
+		s, R, err := signers[i].Sign(m, crt.Pub, B)
+		assert.NoError(t, err)
 		signs = append(signs, s)
 		Rs = append(Rs, R)
 		P.Mul(P, moduli[i])
 	}
 
-	s, r := scheme.Aggregate(signs, Rs[0], P)
-	assert.True(t, scheme.Verify(m, s, r, crt.Pub))
+	s, err := scheme.Aggregate(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, Rs[0], crt.Pub, P)
+	assert.NoError(t, err)
+	assert.True(t, scheme.Verify(m, s, Rs[0], crt.Pub))
+
+	// A partial signature reduced modulo q does not reveal the remainder
+	q := new(bigint.Int).SetBytes(crt.Group.Order())
+	for _, s := range signs {
+		assert.Equal(t, -1, s.Cmp(q))
+	}
+
+	// A wrong partial signature does not aggregate
+	signs[0] = new(bigint.Int).Add(signs[0], bigint.NewInt(1))
+	_, err = scheme.Aggregate(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, Rs[0], crt.Pub, P)
+	assert.ErrorIs(t, err, scheme.ErrAggregate)
 }
 
 func BenchmarkSign(b *testing.B) {
@@ -79,7 +91,7 @@ func BenchmarkSign(b *testing.B) {
 	T := crt.ThresholdT2
 	remainder := crt.Remainder
 	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
-	P := new(gmp.Int).SetInt64(1)
+	P := new(bigint.Int).SetInt64(1)
 
 	signers := make([]*scheme.Signer, 0, T)
 	for i := 0; i < T; i++ {
@@ -99,7 +111,7 @@ func BenchmarkSignAggregation(b *testing.B) {
 	T := crt.ThresholdT2
 	remainder := crt.Remainder
 	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
-	P := new(gmp.Int).SetInt64(1)
+	P := new(bigint.Int).SetInt64(1)
 
 	signers := make([]*scheme.Signer, 0, T)
 	for i := 0; i < T; i++ {
@@ -108,20 +120,20 @@ func BenchmarkSignAggregation(b *testing.B) {
 	}
 
 	m := "Hello World"
-	signs := make([]*gmp.Int, 0, T)
-	R := new(bls12381.G1)
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
 	for _, p := range signers {
-		s, r := p.Sign(m, crt.Pub, B)
+		s, r, _ := p.Sign(m, crt.Pub, B)
 		R = r
 		signs = append(signs, s)
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		P := new(gmp.Int).SetInt64(1)
+		P := new(bigint.Int).SetInt64(1)
 		for j := 0; j < T; j++ {
 			P.Mul(P, moduli[j])
 		}
-		scheme.Aggregate(signs, R, P)
+		scheme.Aggregate(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
 	}
 }
 
@@ -130,7 +142,7 @@ func BenchmarkSignVerify(b *testing.B) {
 	T := crt.ThresholdT2
 	remainder := crt.Remainder
 	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
-	P := new(gmp.Int).SetInt64(1)
+	P := new(bigint.Int).SetInt64(1)
 
 	signers := make([]*scheme.Signer, 0, T)
 	for i := 0; i < T; i++ {
@@ -139,16 +151,84 @@ func BenchmarkSignVerify(b *testing.B) {
 	}
 
 	m := "Hello World"
-	signs := make([]*gmp.Int, 0, T)
-	R := new(bls12381.G1)
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
 	for _, p := range signers {
-		s, r := p.Sign(m, crt.Pub, B)
+		s, r, _ := p.Sign(m, crt.Pub, B)
 		R = r
 		signs = append(signs, s)
 	}
-	s, R := scheme.Aggregate(signs, R, P)
+	s, _ := scheme.Aggregate(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		scheme.Verify(m, s, R, crt.Pub)
 	}
 }
+
+func TestTryNewCRTSharing(t *testing.T) {
+	once()
+	n := len(moduli)
+
+	_, err := scheme.TryNewCRTSharing(n, n+1, moduli)
+	assert.ErrorIs(t, err, scheme.ErrThreshold)
+
+	_, err = scheme.TryNewCRTSharing(n+1, 2, moduli)
+	assert.ErrorIs(t, err, scheme.ErrPartyCount)
+
+	unsorted := slices.Clone(moduli)
+	unsorted[0], unsorted[1] = unsorted[1], unsorted[0]
+	_, err = scheme.TryNewCRTSharing(n, 2, unsorted)
+	assert.ErrorIs(t, err, scheme.ErrModuliUnsorted)
+
+	duplicate := slices.Clone(moduli)
+	duplicate[1] = duplicate[0]
+	_, err = scheme.TryNewCRTSharing(n, 2, duplicate)
+	assert.ErrorIs(t, err, scheme.ErrModuliDuplicate)
+
+	// moduli[0] * moduli[1] shares a factor with moduli[0]
+	composite := slices.Clone(moduli)
+	composite[n-1] = new(bigint.Int).Mul(moduli[0], moduli[1])
+	_, err = scheme.TryNewCRTSharing(n, 2, composite)
+	assert.ErrorIs(t, err, scheme.ErrModuliNotCoprime)
+
+	// A handful of small moduli can never exceed (L+1) * p
+	_, err = scheme.TryNewCRTSharing(2, 1, moduli[:2])
+	assert.ErrorIs(t, err, scheme.ErrPMin1Boundary)
+
+	c, err := scheme.TryNewCRTSharing(n, 2, moduli)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Validate())
+
+	// S = p0 + alpha * p with a random alpha: moved to the same p0, two
+	// sharings still have different secrets
+	d, err := scheme.TryNewCRTSharing(n, 2, moduli)
+	assert.NoError(t, err)
+	p := new(bigint.Int).SetBytes(c.Group.Order())
+	S := new(bigint.Int).Mod(d.Secret, p)
+	S.Sub(d.Secret, S)
+	S.Add(S, new(bigint.Int).Mod(c.Secret, p))
+	assert.NotEqual(t, 0, S.Cmp(c.Secret))
+}
+
+func TestValidate(t *testing.T) {
+	once()
+	assert.NoError(t, crt.Validate())
+
+	tampered := *crt
+	tampered.Remainder = slices.Clone(crt.Remainder)
+	tampered.Remainder[3] = new(bigint.Int).Add(crt.Remainder[3], bigint.NewInt(1))
+	assert.ErrorIs(t, tampered.Validate(), scheme.ErrRemainderMismatch)
+
+	tampered = *crt
+	tampered.ThresholdT1 = crt.ThresholdT1 - 1
+	assert.ErrorIs(t, tampered.Validate(), scheme.ErrThresholdMismatch)
+
+	tampered = *crt
+	tampered.Pub = crt.Group.Generator()
+	assert.ErrorIs(t, tampered.Validate(), scheme.ErrPublicKeyMismatch)
+
+	tampered = *crt
+	tampered.Moduli = slices.Clone(crt.Moduli)
+	tampered.Moduli[0], tampered.Moduli[1] = tampered.Moduli[1], tampered.Moduli[0]
+	assert.ErrorIs(t, tampered.Validate(), scheme.ErrModuliUnsorted)
+}
@@ -0,0 +1,87 @@
+package scheme
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var ErrNoNonceKey = errors.New("scheme: signer has no nonce key")
+
+// NewDerivedSigner creates a pool signer for the drone with the modulus P that
+// also derives nonce pairs into the pool from the secret nonce key with
+// DeriveNonce, so that a weak random number generator can not leak the
+// remainder. The derivation is hedged with fresh randomness from crypto/rand,
+// set Rand to change it.
+func NewDerivedSigner(key []byte, pool *NoncePool, s *bigint.Int, pub group.Element, P *bigint.Int) *Signer {
+	p := NewPoolSigner(pool, s, pub, P)
+	p.nonceKey = slices.Clone(key)
+	p.Rand = rand.Reader
+	return p
+}
+
+// DeriveNonce derives the nonce pair of a signing session in the style of
+// RFC 6979 as
+//
+//	d = H_nonce(key || random || remainder || H(moduli) || m || session || 0)
+//	e = H_nonce(key || random || remainder || H(moduli) || m || session || 1)
+//
+// where moduli are those of the signing set and random are 32 bytes read
+// from Rand, empty if Rand is nil or fails. The pair is added to the pool of
+// the signer and its commitment is returned for B. Without randomness the same
+// inputs derive the same pair again, so session IDs must never repeat, the
+// drone records them with NoncePool.UseSession.
+func (p *Signer) DeriveNonce(m string, moduli []*bigint.Int, session []byte) (NonceCommitment, error) {
+	return p.DeriveNonceMessage(NewMessage([]byte(m)), moduli, session)
+}
+
+// DeriveNonceMessage is DeriveNonce with a message that may be prehashed,
+// m is then the digest and the prehash flag
+func (p *Signer) DeriveNonceMessage(msg Message, moduli []*bigint.Int, session []byte) (NonceCommitment, error) {
+	if p.destroyed() {
+		return NonceCommitment{}, ErrSignerDestroyed
+	}
+	if p.nonceKey == nil || p.pool == nil {
+		return NonceCommitment{}, ErrNoNonceKey
+	}
+	var random []byte
+	if p.Rand != nil {
+		random = make([]byte, 32)
+		if _, err := io.ReadFull(p.Rand, random); err != nil {
+			random = nil
+		}
+	}
+	remainder := p.s.Bytes()
+	digest := moduliDigest(moduli)
+
+	np := p.pool
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	g := np.group
+	parts := [][]byte{p.nonceKey, random, remainder, digest}
+	parts = append(parts, msg.parts()...)
+	parts = append(parts, session, []byte{0})
+	d := p.Suite.HashToScalar(g, dstNonce, parts...)
+	parts[len(parts)-1] = []byte{1}
+	e := p.Suite.HashToScalar(g, dstNonce, parts...)
+	clear(random)
+	clear(remainder)
+	return np.add(d, e), nil
+}
+
+// moduliDigest returns SHA-256(count || moduli) of the moduli in ascending order
+func moduliDigest(moduli []*bigint.Int) []byte {
+	sorted := slices.SortedFunc(slices.Values(moduli), func(x, y *bigint.Int) int { return x.Cmp(y) })
+	buf := binary.AppendUvarint(nil, uint64(len(sorted)))
+	for _, m := range sorted {
+		buf = appendInt(buf, m)
+	}
+	digest := sha256.Sum256(buf)
+	return digest[:]
+}
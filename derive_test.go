@@ -0,0 +1,96 @@
+package scheme_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingReader is a random number generator without entropy
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("no entropy") }
+
+func TestDeriveNonceKAT(t *testing.T) {
+	g := scheme.P256
+	pub := g.NewElement().MulGen(g.NewScalar().SetUint64(7))
+	moduli := []*bigint.Int{bigint.NewInt(101), bigint.NewInt(103), bigint.NewInt(107)}
+	key := []byte("nonce key")
+
+	vectors := []struct {
+		m, session string
+		D, E       string
+	}{
+		{"Hello World", "session-1",
+			"02c4c93a391a1cc548c709aa22213f6bca62d92affdb49bda2007d61dabd455719",
+			"023c68847b4baeecbf9b7abaffed5cd67ee2edb2d343f94a8544b3b8dcfadf6551"},
+		{"Hello World", "session-2",
+			"036c2bfa7bf289f8b396b6360e03fce267439ed1b6e6f9be36fe0fbf2d5ab1501d",
+			"02a03bad89efca8c659be04de4d9a4bbf2d4b99d34f792b4547c514600246676a8"},
+		{"", "",
+			"03fa470e6aa6fa9c1e20e960dd33a135a2281bb15cfecdfea4083f6fcc0a84c6cf",
+			"021cb281fb4dc45cfc9476e2b297cae4d09ad1e059a548119930239ca7b47420e8"},
+	}
+	for _, v := range vectors {
+		signer := scheme.NewDerivedSigner(key, scheme.NewNoncePool(g), bigint.NewInt(42), pub, moduli[1])
+		signer.Rand = nil
+		c, err := signer.DeriveNonce(v.m, moduli, []byte(v.session))
+		assert.NoError(t, err)
+		D, _ := c.D.MarshalBinaryCompress()
+		E, _ := c.E.MarshalBinaryCompress()
+		assert.Equal(t, v.D, hex.EncodeToString(D))
+		assert.Equal(t, v.E, hex.EncodeToString(E))
+
+		// A generator without entropy falls back to the deterministic pair
+		signer.Rand = failingReader{}
+		again, err := signer.DeriveNonce(v.m, []*bigint.Int{moduli[2], moduli[0], moduli[1]}, []byte(v.session))
+		assert.NoError(t, err)
+		assert.True(t, c.D.IsEqual(again.D))
+		assert.True(t, c.E.IsEqual(again.E))
+		assert.NotEqual(t, c.Index, again.Index)
+	}
+}
+
+func TestDeriveNonce(t *testing.T) {
+	once()
+	T := crt.ThresholdT2
+	m, session := "Hello World", []byte("session")
+	signers := make([]*scheme.Signer, 0, T)
+	B := make(scheme.B, 0, T)
+	P := new(bigint.Int).SetInt64(1)
+	for i := 0; i < T; i++ {
+		signer := scheme.NewDerivedSigner([]byte{byte(i)}, scheme.NewNoncePool(crt.Group), crt.Remainder[i], crt.Pub, moduli[i])
+		c, err := signer.DeriveNonce(m, moduli[:T], session)
+		assert.NoError(t, err)
+		signers = append(signers, signer)
+		B = append(B, scheme.BItem{P: moduli[i], E: c.E, D: c.D, Nonce: c.Index})
+		P.Mul(P, moduli[i])
+
+		// Hedged nonces differ for the same inputs
+		again, err := signer.DeriveNonce(m, moduli[:T], session)
+		assert.NoError(t, err)
+		assert.False(t, c.D.IsEqual(again.D))
+	}
+
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
+	for _, signer := range signers {
+		s, r, err := signer.Sign(m, crt.Pub, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		R = r
+	}
+	sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
+	assert.NoError(t, err)
+	assert.True(t, sig.Verify(crt.Pub, m))
+
+	// Signers without a nonce key derive nothing
+	_, err = scheme.NewPoolSigner(scheme.NewNoncePool(crt.Group), crt.Remainder[0], crt.Pub, moduli[0]).
+		DeriveNonce(m, moduli[:T], session)
+	assert.ErrorIs(t, err, scheme.ErrNoNonceKey)
+}
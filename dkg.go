@@ -0,0 +1,402 @@
+package scheme
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrDKGDealing = errors.New("scheme: malformed key generation dealing")
+	ErrDKGDecrypt = errors.New("scheme: can not decrypt the share")
+	ErrDKGKey     = errors.New("scheme: key generation key is not signed by the identity")
+)
+
+// Domain separation tag of the keys encrypting the shares of a key generation
+const dstDKG = dstPrefix + "dkg"
+
+// DKG holds the public parameters of a distributed key generation among the
+// drones of the moduli. Every drone j deals a contribution S_j = p0_j + alpha_j * p
+// like a dealer does, the secret S is their sum and no party learns it.
+// S is up to N times the secret of a dealer, so the recovery bound (L+1) * p
+// is scaled by N, and so is the signing bound which equals it.
+type DKG struct {
+	N           int           // Number of parties
+	ThresholdT1 int           // The minimum number of participants required to recover the secret.
+	ThresholdT2 int           // The minimum number of participants required for threshold signatures.
+	Thresholdt  int           // The maximum number of participants who cannot recover the secret.
+	Moduli      []*bigint.Int // The modulus of each participant.
+	PMin1       *bigint.Int   // The modular product of the minimum number of participants required to recover the secret.
+	PMin2       *bigint.Int   // The modular product of the minimum number of participants required for threshold signatures.
+	PMax        *bigint.Int   // The modular product of the maximum number of participants who cannot recover the secret.
+	Group       Group         // The group of the public key
+}
+
+// DKGDealing is the contribution S_j of a drone to the key generation.
+// Pub, Proof and Commitments are public, every share is sent to its drone
+// only, encrypted with EncryptShare.
+type DKGDealing struct {
+	Pub         group.Element   // S_j * G
+	Proof       *DealingProof   // Coefficient commitments of the shares
+	Commitments []group.Element // Share commitments r_ij * G of every drone i
+	Shares      []*bigint.Int   // The shares r_ij = S_j mod m_i in the order of the moduli
+}
+
+// NewDKG computes the thresholds of a key generation among n = len(moduli)
+// parties, the moduli must be sorted in ascending order and pairwise coprime
+func NewDKG(g Group, t int, moduli []*bigint.Int) (*DKG, error) {
+	n := len(moduli)
+	if n == 0 {
+		return nil, fmt.Errorf("%w: no moduli", ErrPartyCount)
+	}
+	if t <= 0 || t > n {
+		return nil, fmt.Errorf("%w: t = %d, n = %d", ErrThreshold, t, n)
+	}
+	if err := checkModuli(moduli); err != nil {
+		return nil, err
+	}
+	pMax := productMax(moduli, t)
+	L := boundL(pMax)
+	defer bigint.Clear(L)
+	p := groupOrder(g)
+	defer bigint.Clear(p)
+
+	// n * (L+1) * p, both for recovering and for signing
+	left := recoverBound(L, p)
+	defer bigint.Clear(left)
+	left.Mul(left, bigint.NewInt(int64(n)))
+	right := signBound(left)
+	defer bigint.Clear(right)
+	T1, pMin1 := prefixThreshold(moduli, 0, bigint.NewInt(1), left)
+	T2, pMin2 := prefixThreshold(moduli, T1, pMin1, right)
+	if pMin1.Cmp(left) != 1 {
+		return nil, fmt.Errorf("%w: product of all %d moduli is %d bits", ErrPMin1Boundary, n, pMin1.BitLen())
+	}
+	if pMin2.Cmp(right) != 1 {
+		return nil, fmt.Errorf("%w: product of all %d moduli is %d bits", ErrPMin2Boundary, n, pMin2.BitLen())
+	}
+	return &DKG{
+		N:           n,
+		ThresholdT1: T1,
+		ThresholdT2: T2,
+		Thresholdt:  t,
+		Moduli:      slices.Clone(moduli),
+		PMin1:       pMin1,
+		PMin2:       pMin2,
+		PMax:        pMax,
+		Group:       g,
+	}, nil
+}
+
+// Deal draws a random contribution S_j = p0_j + alpha_j * p with p0_j in
+// [0, p) and alpha_j in [0, L], and shares it among all the drones
+func (dkg *DKG) Deal() *DKGDealing {
+	g := dkg.Group
+	L := boundL(dkg.PMax)
+	defer bigint.Clear(L)
+	p := groupOrder(g)
+	defer bigint.Clear(p)
+
+	S := randInt(L)
+	defer bigint.Clear(S)
+	S.Mul(S, p)
+	p.Sub(p, bigint.NewInt(1))
+	p0 := randInt(p)
+	S.Add(S, p0)
+	bigint.Clear(p0)
+
+	shares := make([]*bigint.Int, 0, dkg.N)
+	for _, m := range dkg.Moduli {
+		shares = append(shares, new(bigint.Int).Mod(S, m))
+	}
+	return &DKGDealing{
+		Pub:         g.NewElement().MulGen(IntToScalar(g, S)),
+		Proof:       dealingProof(g, dkg.Moduli, shares),
+		Commitments: shareCommitments(g, shares),
+		Shares:      shares,
+	}
+}
+
+// VerifyShare checks the share that the drone with the modulus received from
+// the dealing against the dealing proof and the share commitment
+func (dkg *DKG) VerifyShare(dealing *DKGDealing, modulus, share *bigint.Int) error {
+	i := dkg.index(modulus)
+	if i < 0 {
+		return ErrUnknownModulus
+	}
+	if err := dkg.CheckDealing(dealing); err != nil {
+		return err
+	}
+	if err := VerifyShare(dealing.Pub, modulus, share, dealing.Proof); err != nil {
+		return err
+	}
+	if !dealing.Commitments[i].IsEqual(shareCommitment(dkg.Group, share)) {
+		return fmt.Errorf("%w: share commitment differs", ErrShareMismatch)
+	}
+	return nil
+}
+
+// CheckDealing checks that the public part of the dealing fits the key
+// generation, the TA runs it before it stores a dealing
+func (dkg *DKG) CheckDealing(dealing *DKGDealing) error {
+	if dealing == nil || dealing.Proof == nil || !sameGroup(dkg.Group, dealing.Pub) ||
+		len(dealing.Commitments) != dkg.N || !sameGroup(dkg.Group, dealing.Commitments...) ||
+		!slices.EqualFunc(dealing.Proof.Moduli, dkg.Moduli, func(x, y *bigint.Int) bool { return x.Cmp(y) == 0 }) {
+		return ErrDKGDealing
+	}
+	return nil
+}
+
+// Remainder returns the remainder of the drone with the modulus, the sum of
+// the verified shares it received from all the dealings
+func (dkg *DKG) Remainder(modulus *bigint.Int, shares []*bigint.Int) (*bigint.Int, error) {
+	if dkg.index(modulus) < 0 {
+		return nil, ErrUnknownModulus
+	}
+	if len(shares) != dkg.N {
+		return nil, fmt.Errorf("%w: %d shares for %d dealings", ErrDKGDealing, len(shares), dkg.N)
+	}
+	r := new(bigint.Int)
+	for _, s := range shares {
+		r.Add(r, s)
+	}
+	return r.Mod(r, modulus), nil
+}
+
+// PublicKey returns the group public key, the sum of the public keys of all
+// the dealings
+func (dkg *DKG) PublicKey(dealings []*DKGDealing) (group.Element, error) {
+	if len(dealings) != dkg.N {
+		return nil, fmt.Errorf("%w: %d dealings for %d parties", ErrDKGDealing, len(dealings), dkg.N)
+	}
+	pub := dkg.Group.Identity()
+	for _, d := range dealings {
+		if err := dkg.CheckDealing(d); err != nil {
+			return nil, err
+		}
+		pub.Add(pub, d.Pub)
+	}
+	return pub, nil
+}
+
+// VerifyShareCommitment checks the commitment Y = r_i * G that drone i
+// publishes for its remainder. The remainder is the sum of the shares minus
+// k * m_i for some 0 <= k < N, so Y must equal sum(r_ij * G) - k * m_i * G.
+func (dkg *DKG) VerifyShareCommitment(dealings []*DKGDealing, i int, Y group.Element) error {
+	if i < 0 || i >= dkg.N || len(dealings) != dkg.N || !sameGroup(dkg.Group, Y) {
+		return ErrCommitmentMismatch
+	}
+	g := dkg.Group
+	X := g.NewElement().Neg(Y)
+	for _, d := range dealings {
+		if err := dkg.CheckDealing(d); err != nil {
+			return err
+		}
+		X.Add(X, d.Commitments[i])
+	}
+	step := g.NewElement().MulGen(IntToScalar(g, dkg.Moduli[i]))
+	km := g.Identity()
+	for k := 0; k < dkg.N; k++ {
+		if X.IsEqual(km) {
+			return nil
+		}
+		km.Add(km, step)
+	}
+	return fmt.Errorf("%w: index %d", ErrCommitmentMismatch, i)
+}
+
+// Params returns the public parameters of the generated sharing
+// with the public key and the share commitments
+func (dkg *DKG) Params(pub group.Element, commitments []group.Element) *GroupParams {
+	weight := make([]int, 0, dkg.N)
+	for _, m := range dkg.Moduli {
+		weight = append(weight, m.BitLen())
+	}
+	return &GroupParams{
+		N:           dkg.N,
+		ThresholdT1: dkg.ThresholdT1,
+		ThresholdT2: dkg.ThresholdT2,
+		Thresholdt:  dkg.Thresholdt,
+		Dealers:     dkg.N,
+		Weight:      weight,
+		Moduli:      slices.Clone(dkg.Moduli),
+		PMin1:       dkg.PMin1,
+		PMin2:       dkg.PMin2,
+		PMax:        dkg.PMax,
+		Pub:         pub,
+		Group:       dkg.Group,
+		Commitments: slices.Clone(commitments),
+	}
+}
+
+// index returns the index of the modulus, or -1 if it is not part of the key generation
+func (dkg *DKG) index(modulus *bigint.Int) int {
+	if modulus == nil {
+		return -1
+	}
+	return slices.IndexFunc(dkg.Moduli, func(m *bigint.Int) bool { return m.Cmp(modulus) == 0 })
+}
+
+// MarshalBinary encodes the public part of the dealing as
+//
+//	group || pub || commitments[N] || proof
+//
+// the shares are not encoded
+func (d *DKGDealing) MarshalBinary() ([]byte, error) {
+	if d.Proof == nil || d.Pub == nil {
+		return nil, ErrDKGDealing
+	}
+	g := groupOf(d.Pub.Group())
+	if !sameGroup(g, d.Commitments...) {
+		return nil, ErrDKGDealing
+	}
+	proof, err := d.Proof.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte{byte(g.ID())}
+	buf = append(buf, elementBytes(d.Pub)...)
+	buf = binary.AppendUvarint(buf, uint64(len(d.Commitments)))
+	for _, Y := range d.Commitments {
+		buf = append(buf, elementBytes(Y)...)
+	}
+	return append(buf, proof...), nil
+}
+
+// Digest returns SHA-256 of the public part of the dealing. Every drone
+// commits to the digest of its dealing before any dealing is revealed, so no
+// drone chooses its dealing after seeing the others.
+func (d *DKGDealing) Digest() ([]byte, error) {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write([]byte(dstDKG + "-dealing"))
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// UnmarshalBinary decodes the public part of a dealing produced by MarshalBinary
+func (d *DKGDealing) UnmarshalBinary(data []byte) error {
+	r := &reader{buf: data}
+	g, err := GroupByID(GroupID(r.byte()))
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return err
+	}
+	pub := r.element(g)
+	n := r.int()
+	if r.err == nil && n > len(r.buf) {
+		r.fail()
+	}
+	commitments := make([]group.Element, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		commitments = append(commitments, r.element(g))
+	}
+	if r.err != nil {
+		return r.err
+	}
+	proof := new(DealingProof)
+	if err := proof.UnmarshalBinary(r.buf); err != nil {
+		return err
+	}
+	*d = DKGDealing{Pub: pub, Proof: proof, Commitments: commitments}
+	return nil
+}
+
+// EncryptShare encrypts the share for the drone with the modulus under the
+// X25519 key agreement of the dealer key from and the drone key to, with
+// AES-256-GCM. The modulus is authenticated, so the share can not be
+// replayed to another drone.
+func EncryptShare(from *ecdh.PrivateKey, to *ecdh.PublicKey, modulus, share *bigint.Int) ([]byte, error) {
+	aead, err := shareCipher(from, from.PublicKey(), to)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(share.Bytes())+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, share.Bytes(), modulus.Bytes()), nil
+}
+
+// SignDKGKey signs the X25519 key the drone with the modulus receives its
+// shares under with its long-term identity. The drones pin the identities of
+// each other and check the signature with VerifyDKGKey before encrypting a
+// share, so the TA relaying the keys can not replace them with its own.
+func SignDKGKey(identity ed25519.PrivateKey, key *ecdh.PublicKey, modulus *bigint.Int) []byte {
+	return ed25519.Sign(identity, dkgKeyMessage(key, modulus))
+}
+
+// VerifyDKGKey checks the signature of SignDKGKey under the identity
+func VerifyDKGKey(identity ed25519.PublicKey, key *ecdh.PublicKey, modulus *bigint.Int, sig []byte) error {
+	if len(identity) != ed25519.PublicKeySize || key == nil || modulus == nil ||
+		!ed25519.Verify(identity, dkgKeyMessage(key, modulus), sig) {
+		return ErrDKGKey
+	}
+	return nil
+}
+
+// dkgKeyMessage returns dstDKG || "-key" || key || modulus
+func dkgKeyMessage(key *ecdh.PublicKey, modulus *bigint.Int) []byte {
+	buf := append([]byte(dstDKG+"-key"), key.Bytes()...)
+	return appendInt(buf, modulus)
+}
+
+// DecryptShare decrypts a share encrypted with EncryptShare by the dealer
+// key from for the drone key to
+func DecryptShare(to *ecdh.PrivateKey, from *ecdh.PublicKey, modulus *bigint.Int, ciphertext []byte) (*bigint.Int, error) {
+	aead, err := shareCipher(to, from, to.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDKGDecrypt
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	share, err := aead.Open(nil, nonce, sealed, modulus.Bytes())
+	if err != nil {
+		return nil, ErrDKGDecrypt
+	}
+	defer clear(share)
+	return new(bigint.Int).SetBytes(share), nil
+}
+
+// shareCipher returns AES-256-GCM under the key
+// HKDF-SHA256(X25519(priv, peer), dstDKG || from || to)
+func shareCipher(priv *ecdh.PrivateKey, from, to *ecdh.PublicKey) (cipher.AEAD, error) {
+	peer := to
+	if priv.PublicKey().Equal(to) {
+		peer = from
+	}
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(secret)
+	info := slices.Concat([]byte(dstDKG), from.Bytes(), to.Bytes())
+	key, err := hkdf.Key(sha256.New, secret, nil, string(info), 32)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,162 @@
+package scheme_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDKG(t *testing.T) {
+	n := 10
+	mod := scheme.GenerateNumber([]int{512}, n)
+	dkg, err := scheme.NewDKG(scheme.P256, 3, mod)
+	assert.NoError(t, err)
+	g := dkg.Group
+
+	keys := make([]*ecdh.PrivateKey, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	// Every drone deals and sends the encrypted shares, only the public part is broadcast
+	dealings := make([]*scheme.DKGDealing, 0, n)
+	ciphertexts := make([][][]byte, 0, n)
+	for j := 0; j < n; j++ {
+		d := dkg.Deal()
+		data, err := d.MarshalBinary()
+		assert.NoError(t, err)
+		public := new(scheme.DKGDealing)
+		assert.NoError(t, public.UnmarshalBinary(data))
+		dealings = append(dealings, public)
+		c := make([][]byte, 0, n)
+		for i := 0; i < n; i++ {
+			ct, err := scheme.EncryptShare(keys[j], keys[i].PublicKey(), mod[i], d.Shares[i])
+			assert.NoError(t, err)
+			c = append(c, ct)
+		}
+		ciphertexts = append(ciphertexts, c)
+	}
+
+	// Every drone decrypts and verifies its shares and commits to its remainder
+	remainders := make([]*bigint.Int, 0, n)
+	Y := make([]group.Element, 0, n)
+	for i := 0; i < n; i++ {
+		shares := make([]*bigint.Int, 0, n)
+		for j := 0; j < n; j++ {
+			share, err := scheme.DecryptShare(keys[i], keys[j].PublicKey(), mod[i], ciphertexts[j][i])
+			assert.NoError(t, err)
+			assert.NoError(t, dkg.VerifyShare(dealings[j], mod[i], share))
+			shares = append(shares, share)
+		}
+		r, err := dkg.Remainder(mod[i], shares)
+		assert.NoError(t, err)
+		remainders = append(remainders, r)
+		Y = append(Y, g.NewElement().MulGen(scheme.IntToScalar(g, r)))
+		assert.NoError(t, dkg.VerifyShareCommitment(dealings, i, Y[i]))
+	}
+	assert.Error(t, dkg.VerifyShareCommitment(dealings, 0, Y[1]))
+
+	// The remainders share the secret behind the public key
+	pub, err := dkg.PublicKey(dealings)
+	assert.NoError(t, err)
+	S := scheme.ReconstructSecret(mod, remainders)
+	assert.True(t, pub.IsEqual(g.NewElement().MulGen(scheme.IntToScalar(g, S))))
+	params := dkg.Params(pub, Y)
+	assert.NoError(t, params.Validate())
+
+	// The secret is a sum of n dealt secrets, so is the recovery bound
+	assert.Equal(t, n, params.Dealers)
+	single := *params
+	single.Dealers = 1
+	bound := single.RecoveryBound()
+	bound.Mul(bound, bigint.NewInt(int64(n)))
+	assert.Zero(t, bound.Cmp(params.RecoveryBound()))
+	assert.Equal(t, 1, bound.Cmp(S))
+	ok, _ := params.CanRecover(mod[:dkg.ThresholdT1])
+	assert.True(t, ok)
+	ok, _ = params.CanRecover(mod[:dkg.ThresholdT1-1])
+	assert.False(t, ok)
+	assert.Equal(t, dkg.ThresholdT1, dkg.ThresholdT2)
+	data, err := params.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.GroupParams)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, n, decoded.Dealers)
+	single.Dealers = 0
+	assert.ErrorIs(t, single.Validate(), scheme.ErrDealers)
+	ok, _ = params.CanSign(mod[:dkg.ThresholdT2])
+	assert.True(t, ok)
+
+	// The first ThresholdT2 drones sign
+	T := dkg.ThresholdT2
+	B := make(scheme.B, 0, T)
+	signers := make([]*scheme.Signer, 0, T)
+	P := new(bigint.Int).SetInt64(1)
+	for i := 0; i < T; i++ {
+		pool := scheme.NewNoncePool(g)
+		c := pool.Generate(1)[0]
+		B = append(B, scheme.BItem{P: mod[i], E: c.E, D: c.D, Nonce: c.Index})
+		signers = append(signers, scheme.NewPoolSigner(pool, remainders[i], pub, mod[i]))
+		P.Mul(P, mod[i])
+	}
+	m := "Hello World"
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
+	for _, signer := range signers {
+		s, r, err := signer.Sign(m, pub, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		R = r
+	}
+	sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, pub, P)
+	assert.NoError(t, err)
+	assert.True(t, sig.Verify(pub, m))
+
+	// The digest a drone commits to is that of the revealed dealing
+	digest, err := dealings[0].Digest()
+	assert.NoError(t, err)
+	again, err := dealings[0].Digest()
+	assert.NoError(t, err)
+	assert.Equal(t, digest, again)
+	other, err := dealings[1].Digest()
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, other)
+	assert.NoError(t, dkg.CheckDealing(dealings[0]))
+	assert.ErrorIs(t, dkg.CheckDealing(&scheme.DKGDealing{Pub: pub}), scheme.ErrDKGDealing)
+
+	// Tampered shares and ciphertexts are caught
+	wrong := new(bigint.Int).Add(remainders[0], bigint.NewInt(1))
+	assert.ErrorIs(t, dkg.VerifyShare(dealings[0], mod[0], wrong), scheme.ErrShareMismatch)
+	_, err = scheme.DecryptShare(keys[2], keys[0].PublicKey(), mod[1], ciphertexts[0][1])
+	assert.ErrorIs(t, err, scheme.ErrDKGDecrypt)
+	_, err = scheme.DecryptShare(keys[1], keys[0].PublicKey(), mod[2], ciphertexts[0][1])
+	assert.ErrorIs(t, err, scheme.ErrDKGDecrypt)
+}
+
+func TestDKGKey(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 2)
+	pub, identity, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	sig := scheme.SignDKGKey(identity, key.PublicKey(), mod[0])
+	assert.NoError(t, scheme.VerifyDKGKey(pub, key.PublicKey(), mod[0], sig))
+
+	// The TA can not swap the key, the modulus or the identity
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, scheme.VerifyDKGKey(pub, other.PublicKey(), mod[0], sig), scheme.ErrDKGKey)
+	assert.ErrorIs(t, scheme.VerifyDKGKey(pub, key.PublicKey(), mod[1], sig), scheme.ErrDKGKey)
+	pub2, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, scheme.VerifyDKGKey(pub2, key.PublicKey(), mod[0], sig), scheme.ErrDKGKey)
+	assert.ErrorIs(t, scheme.VerifyDKGKey(nil, key.PublicKey(), mod[0], sig), scheme.ErrDKGKey)
+}
@@ -0,0 +1,90 @@
+package scheme
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+)
+
+var ErrInvalidWeight = errors.New("scheme: weight must be a multiple of 8 of at least MinWeight")
+
+// MinWeight is the smallest weight of an enrolled modulus, the remainder of a
+// smaller modulus is found from the commitments of the drone by a search
+const MinWeight = 256
+
+// Enroll adds a drone with a fresh prime modulus of the given weight to the
+// sharing and returns its modulus and remainder. The secret, the public key and
+// the shares of the existing drones stay the same, the thresholds and products
+// are recomputed for the new moduli. On error the sharing is left unchanged.
+//
+// A modulus among the t largest raises PMax and with it L, Refresh afterwards
+// re-randomizes the secret over the new range.
+func (crt *CRTSharing) Enroll(weight int) (*bigint.Int, *bigint.Int, error) {
+	if crt.Secret == nil {
+		return nil, nil, ErrNoSecret
+	}
+	if weight < MinWeight || weight%8 != 0 {
+		return nil, nil, fmt.Errorf("%w: %d", ErrInvalidWeight, weight)
+	}
+
+	// A prime that is not a modulus yet is coprime to all of them
+	var m *bigint.Int
+	for {
+		m = GenerateRangePrime(weight, crt.N+1)
+		if !slices.ContainsFunc(crt.Moduli, func(x *bigint.Int) bool { return x.Cmp(m) == 0 }) {
+			break
+		}
+	}
+	i, _ := slices.BinarySearchFunc(crt.Moduli, m, func(x, y *bigint.Int) int { return x.Cmp(y) })
+	moduli := slices.Insert(slices.Clone(crt.Moduli), i, m)
+
+	// Recompute the thresholds of the grown sharing
+	pMax := productMax(moduli, crt.Thresholdt)
+	L := boundL(pMax)
+	defer bigint.Clear(L)
+	p := groupOrder(crt.Group)
+	defer bigint.Clear(p)
+	left := recoverBound(L, p)
+	defer bigint.Clear(left)
+	if crt.Secret.Cmp(left) == 1 {
+		bigint.Clear(pMax)
+		return nil, nil, ErrSecretBoundary
+	}
+	right := signBound(crt.Secret)
+	defer bigint.Clear(right)
+	var revoked []bool
+	if crt.Revoked != nil {
+		revoked = slices.Insert(slices.Clone(crt.Revoked), i, false)
+	}
+	active := activeModuli(moduli, revoked)
+	T1, pMin1 := prefixThreshold(active, 0, bigint.NewInt(1), left)
+	T2, pMin2 := prefixThreshold(active, T1, pMin1, right)
+	var err error
+	switch {
+	case pMin1.Cmp(left) != 1:
+		err = ErrPMin1Boundary
+	case pMin2.Cmp(right) != 1:
+		err = ErrPMin2Boundary
+	}
+	if err != nil {
+		bigint.Clear(pMax)
+		bigint.Clear(pMin1)
+		bigint.Clear(pMin2)
+		return nil, nil, err
+	}
+
+	r := new(bigint.Int).Mod(crt.Secret, m)
+	crt.N++
+	crt.ThresholdT1, crt.ThresholdT2 = T1, T2
+	crt.Weight = slices.Insert(slices.Clone(crt.Weight), i, m.BitLen())
+	crt.Moduli = moduli
+	crt.Revoked = revoked
+	crt.Remainder = slices.Insert(slices.Clone(crt.Remainder), i, r)
+	crt.PMin1, crt.PMin2, crt.PMax = pMin1, pMin2, pMax
+	if crt.Commitments != nil {
+		crt.Commitments = slices.Insert(slices.Clone(crt.Commitments), i, shareCommitment(crt.Group, r))
+	}
+	return m, r, nil
+}
@@ -0,0 +1,64 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnroll(t *testing.T) {
+	n := 8
+	mod := scheme.GenerateNumber([]int{384}, n)
+	crt, err := scheme.TryNewCRTSharing(n, 4, mod)
+	assert.NoError(t, err)
+	pub := crt.Pub
+	remainder := crt.Remainder[0]
+
+	m, r, err := crt.Enroll(256)
+	assert.NoError(t, err)
+	assert.Equal(t, 256, m.BitLen())
+	assert.Equal(t, n+1, crt.N)
+	assert.NoError(t, crt.Validate())
+	assert.True(t, pub.IsEqual(crt.Pub))
+	assert.Same(t, remainder, crt.Remainder[1])
+
+	// The new modulus is the smallest, it takes part in recovering the secret
+	assert.Equal(t, 0, crt.Moduli[0].Cmp(m))
+	T1 := crt.ThresholdT1
+	assert.Equal(t, 0, scheme.ReconstructSecret(crt.Moduli[:T1], crt.Remainder[:T1]).Cmp(crt.Secret))
+	assert.NoError(t, scheme.VerifyShare(crt.Pub, m, r, crt.Proof()))
+
+	_, _, err = crt.Enroll(12)
+	assert.ErrorIs(t, err, scheme.ErrInvalidWeight)
+
+	// The remainder of a small modulus leaks through its commitments
+	_, _, err = crt.Enroll(scheme.MinWeight - 8)
+	assert.ErrorIs(t, err, scheme.ErrInvalidWeight)
+	assert.Equal(t, n+1, crt.N)
+
+	crt.Secret = nil
+	_, _, err = crt.Enroll(256)
+	assert.ErrorIs(t, err, scheme.ErrNoSecret)
+	assert.Equal(t, n+1, crt.N)
+}
+
+func TestEnrollLargeModulus(t *testing.T) {
+	// A modulus larger than all the others raises PMax and L
+	mod := scheme.GenerateNumber([]int{256}, 4)
+	crt, err := scheme.TryNewCRTSharing(4, 1, mod)
+	assert.NoError(t, err)
+	pMax := new(bigint.Int).Set(crt.PMax)
+
+	m, _, err := crt.Enroll(2048)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, m.Cmp(crt.PMax))
+	assert.Equal(t, 1, crt.PMax.Cmp(pMax))
+	assert.NoError(t, crt.Validate())
+
+	// Refresh re-randomizes the secret over the grown range
+	_, err = crt.Refresh()
+	assert.NoError(t, err)
+	assert.NoError(t, crt.Validate())
+}
@@ -8,12 +8,13 @@ require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/ncw/gmp v1.0.5
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.53.0
 )
 
 require (
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/crypto v0.53.0 // indirect
 	golang.org/x/sys v0.46.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,159 @@
+package scheme
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+// GroupID identifies the prime-order group a sharing or signature lives in
+type GroupID byte
+
+const (
+	GroupBLS12381G1   GroupID = 1 // G1 of the BLS12-381 curve
+	GroupP256         GroupID = 2 // NIST P-256
+	GroupRistretto255 GroupID = 3 // ristretto255
+)
+
+var ErrUnknownGroup = errors.New("scheme: unknown group")
+
+// Group is a prime-order group the scheme can run on. It extends the groups of
+// circl with an identifier and the group order, which the CRT sharing uses as
+// the prime p.
+type Group interface {
+	group.Group
+	// ID returns the identifier carried in encodings
+	ID() GroupID
+	// Name returns the name of the group, used for domain separation
+	Name() string
+	// Order returns the order of the group in big-endian order
+	Order() []byte
+	// ScalarToInt converts a scalar of the group to an integer in [0, order)
+	ScalarToInt(s group.Scalar) *bigint.Int
+}
+
+// circlGroup adapts a group of circl
+type circlGroup struct {
+	group.Group
+	id     GroupID
+	name   string
+	order  []byte
+	lilEnd bool // Scalars are encoded in little-endian order
+}
+
+func (g *circlGroup) ID() GroupID    { return g.id }
+func (g *circlGroup) Name() string   { return g.name }
+func (g *circlGroup) Order() []byte  { return slices.Clone(g.order) }
+func (g *circlGroup) String() string { return g.name }
+
+func (g *circlGroup) ScalarToInt(s group.Scalar) *bigint.Int {
+	buf, _ := s.MarshalBinary()
+	if g.lilEnd {
+		slices.Reverse(buf)
+	}
+	x := new(bigint.Int).SetBytes(buf)
+	clear(buf)
+	return x
+}
+
+var (
+	// P256 is the NIST P-256 group
+	P256 Group = &circlGroup{
+		Group: group.P256,
+		id:    GroupP256,
+		name:  "P256",
+		order: elliptic.P256().Params().N.Bytes(),
+	}
+	// Ristretto255 is the prime-order group built on edwards25519
+	Ristretto255 Group = &circlGroup{
+		Group:  group.Ristretto255,
+		id:     GroupRistretto255,
+		name:   "ristretto255",
+		order:  ristretto255Order(),
+		lilEnd: true,
+	}
+
+	// DefaultGroup is used when no group is given
+	DefaultGroup = BLS12381G1
+)
+
+// l = 2^252 + 27742317777372353535851937790883648493
+func ristretto255Order() []byte {
+	l, _ := new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+	return l.Bytes()
+}
+
+// GroupByID returns the group with the given identifier
+func GroupByID(id GroupID) (Group, error) {
+	switch id {
+	case GroupBLS12381G1:
+		return BLS12381G1, nil
+	case GroupP256:
+		return P256, nil
+	case GroupRistretto255:
+		return Ristretto255, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownGroup, id)
+}
+
+// groupOf returns the Group of an element or scalar of circl. The groups of
+// circl are not all comparable, so they are told apart by name.
+func groupOf(g group.Group) Group {
+	if gg, ok := g.(Group); ok {
+		return gg
+	}
+	if s, ok := g.(fmt.Stringer); ok {
+		switch s.String() {
+		case group.P256.(fmt.Stringer).String():
+			return P256
+		case group.Ristretto255.(fmt.Stringer).String():
+			return Ristretto255
+		}
+	}
+	return nil
+}
+
+// sameGroup reports whether all the elements belong to the group g
+func sameGroup(g Group, elems ...group.Element) bool {
+	for _, e := range elems {
+		if e == nil || groupOf(e.Group()) != g {
+			return false
+		}
+	}
+	return g != nil
+}
+
+// groupOrder returns the order of the group as an integer
+func groupOrder(g Group) *bigint.Int {
+	return new(bigint.Int).SetBytes(g.Order())
+}
+
+// IntToScalar reduces the integer modulo the group order and converts it to a scalar
+func IntToScalar(g Group, x *bigint.Int) group.Scalar {
+	order := groupOrder(g)
+	r := new(bigint.Int).Mod(x, order)
+	buf := r.Bytes()
+	b := new(big.Int).SetBytes(buf)
+	clear(buf)
+	bigint.Clear(r)
+	bigint.Clear(order)
+	s := g.NewScalar().SetBigInt(b)
+	bigint.ClearBig(b)
+	return s
+}
+
+// ScalarToInt converts a scalar of any supported group to an integer
+func ScalarToInt(s group.Scalar) *bigint.Int {
+	return groupOf(s.Group()).ScalarToInt(s)
+}
+
+// elementBytes returns the compressed encoding of the element
+func elementBytes(e group.Element) []byte {
+	b, _ := e.MarshalBinaryCompress()
+	return b
+}
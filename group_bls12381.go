@@ -0,0 +1,314 @@
+package scheme
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/ecc/bls12381"
+	"github.com/cloudflare/circl/expander"
+	"github.com/cloudflare/circl/group"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// BLS12381G1 is the group G1 of the BLS12-381 curve
+var BLS12381G1 Group = bls12381Group{}
+
+var errBLS12381Type = errors.New("scheme: element or scalar is not of BLS12-381 G1")
+
+type bls12381Group struct{}
+
+type bls12381Element struct {
+	p bls12381.G1
+}
+
+type bls12381Scalar struct {
+	s bls12381.Scalar
+}
+
+func (g bls12381Group) ID() GroupID    { return GroupBLS12381G1 }
+func (g bls12381Group) Name() string   { return "BLS12381G1" }
+func (g bls12381Group) String() string { return g.Name() }
+func (g bls12381Group) Order() []byte  { return bls12381.Order() }
+
+func (g bls12381Group) Params() *group.Params {
+	return &group.Params{
+		ElementLength:           bls12381.G1Size,
+		CompressedElementLength: bls12381.G1SizeCompressed,
+		ScalarLength:            bls12381.ScalarSize,
+	}
+}
+
+func (g bls12381Group) ScalarToInt(s group.Scalar) *bigint.Int {
+	buf, _ := toBLS12381Scalar(s).s.MarshalBinary()
+	x := new(bigint.Int).SetBytes(buf)
+	clear(buf)
+	return x
+}
+
+func (g bls12381Group) NewElement() group.Element { return g.Identity() }
+
+func (g bls12381Group) NewScalar() group.Scalar {
+	s := new(bls12381Scalar)
+	s.s.SetUint64(0)
+	return s
+}
+
+func (g bls12381Group) Identity() group.Element {
+	e := new(bls12381Element)
+	e.p.SetIdentity()
+	return e
+}
+
+func (g bls12381Group) Generator() group.Element {
+	return &bls12381Element{*bls12381.G1Generator()}
+}
+
+func (g bls12381Group) RandomElement(rnd io.Reader) group.Element {
+	return g.NewElement().MulGen(g.RandomScalar(rnd))
+}
+
+func (g bls12381Group) RandomScalar(rnd io.Reader) group.Scalar {
+	s := new(bls12381Scalar)
+	if err := s.s.Random(rnd); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (g bls12381Group) RandomNonZeroScalar(rnd io.Reader) group.Scalar {
+	for {
+		if s := g.RandomScalar(rnd); !s.IsZero() {
+			return s
+		}
+	}
+}
+
+func (g bls12381Group) HashToElement(msg, dst []byte) group.Element {
+	e := new(bls12381Element)
+	e.p.Hash(msg, dst)
+	return e
+}
+
+func (g bls12381Group) HashToElementNonUniform(msg, dst []byte) group.Element {
+	e := new(bls12381Element)
+	e.p.Encode(msg, dst)
+	return e
+}
+
+func (g bls12381Group) HashToScalar(msg, dst []byte) group.Scalar {
+	buf := expander.NewExpanderMD(crypto.SHA256, dst).Expand(msg, 48)
+	s := new(bls12381Scalar)
+	s.s.SetBytes(buf)
+	return s
+}
+
+func toBLS12381Element(e group.Element) *bls12381Element {
+	if ee, ok := e.(*bls12381Element); ok {
+		return ee
+	}
+	panic(errBLS12381Type)
+}
+
+func toBLS12381Scalar(s group.Scalar) *bls12381Scalar {
+	if ss, ok := s.(*bls12381Scalar); ok {
+		return ss
+	}
+	panic(errBLS12381Type)
+}
+
+func (e *bls12381Element) Group() group.Group { return BLS12381G1 }
+func (e *bls12381Element) String() string     { return e.p.String() }
+func (e *bls12381Element) IsIdentity() bool   { return e.p.IsIdentity() }
+
+func (e *bls12381Element) IsEqual(x group.Element) bool {
+	return e.p.IsEqual(&toBLS12381Element(x).p)
+}
+
+func (e *bls12381Element) Set(x group.Element) group.Element {
+	e.p = toBLS12381Element(x).p
+	return e
+}
+
+func (e *bls12381Element) Copy() group.Element {
+	return &bls12381Element{e.p}
+}
+
+func (e *bls12381Element) CMov(b int, x group.Element) group.Element {
+	if b != 0 && b != 1 {
+		panic(group.ErrSelector)
+	}
+	if b == 1 {
+		e.p = toBLS12381Element(x).p
+	}
+	return e
+}
+
+func (e *bls12381Element) CSelect(b int, x, y group.Element) group.Element {
+	if b != 0 && b != 1 {
+		panic(group.ErrSelector)
+	}
+	if b == 1 {
+		e.p = toBLS12381Element(x).p
+	} else {
+		e.p = toBLS12381Element(y).p
+	}
+	return e
+}
+
+func (e *bls12381Element) Add(x, y group.Element) group.Element {
+	e.p.Add(&toBLS12381Element(x).p, &toBLS12381Element(y).p)
+	return e
+}
+
+func (e *bls12381Element) Dbl(x group.Element) group.Element {
+	e.p = toBLS12381Element(x).p
+	e.p.Double()
+	return e
+}
+
+func (e *bls12381Element) Neg(x group.Element) group.Element {
+	e.p = toBLS12381Element(x).p
+	e.p.Neg()
+	return e
+}
+
+func (e *bls12381Element) Mul(x group.Element, s group.Scalar) group.Element {
+	e.p.ScalarMult(&toBLS12381Scalar(s).s, &toBLS12381Element(x).p)
+	return e
+}
+
+func (e *bls12381Element) MulGen(s group.Scalar) group.Element {
+	e.p.ScalarMult(&toBLS12381Scalar(s).s, bls12381.G1Generator())
+	return e
+}
+
+func (e *bls12381Element) MarshalBinary() ([]byte, error) {
+	return e.p.Bytes(), nil
+}
+
+func (e *bls12381Element) MarshalBinaryCompress() ([]byte, error) {
+	return e.p.BytesCompressed(), nil
+}
+
+// UnmarshalBinary accepts both the compressed and the uncompressed encoding
+func (e *bls12381Element) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return group.ErrUnmarshal
+	}
+	size := bls12381.G1Size
+	if data[0]&0x80 != 0 {
+		size = bls12381.G1SizeCompressed
+	}
+	if len(data) != size {
+		return group.ErrUnmarshal
+	}
+	return e.p.SetBytes(data)
+}
+
+func (s *bls12381Scalar) Group() group.Group { return BLS12381G1 }
+func (s *bls12381Scalar) String() string     { return s.s.String() }
+func (s *bls12381Scalar) IsZero() bool       { return s.s.IsZero() == 1 }
+
+func (s *bls12381Scalar) IsEqual(x group.Scalar) bool {
+	return s.s.IsEqual(&toBLS12381Scalar(x).s) == 1
+}
+
+func (s *bls12381Scalar) Set(x group.Scalar) group.Scalar {
+	s.s.Set(&toBLS12381Scalar(x).s)
+	return s
+}
+
+func (s *bls12381Scalar) Copy() group.Scalar {
+	c := new(bls12381Scalar)
+	c.s.Set(&s.s)
+	return c
+}
+
+func (s *bls12381Scalar) SetUint64(x uint64) group.Scalar {
+	s.s.SetUint64(x)
+	return s
+}
+
+func (s *bls12381Scalar) SetBigInt(x *big.Int) group.Scalar {
+	r := new(big.Int).Mod(x, new(big.Int).SetBytes(bls12381.Order()))
+	s.s.SetBytes(r.Bytes())
+	return s
+}
+
+func (s *bls12381Scalar) CMov(b int, x group.Scalar) group.Scalar {
+	if b != 0 && b != 1 {
+		panic(group.ErrSelector)
+	}
+	if b == 1 {
+		s.s.Set(&toBLS12381Scalar(x).s)
+	}
+	return s
+}
+
+func (s *bls12381Scalar) CSelect(b int, x, y group.Scalar) group.Scalar {
+	if b != 0 && b != 1 {
+		panic(group.ErrSelector)
+	}
+	if b == 1 {
+		s.s.Set(&toBLS12381Scalar(x).s)
+	} else {
+		s.s.Set(&toBLS12381Scalar(y).s)
+	}
+	return s
+}
+
+func (s *bls12381Scalar) Add(x, y group.Scalar) group.Scalar {
+	s.s.Add(&toBLS12381Scalar(x).s, &toBLS12381Scalar(y).s)
+	return s
+}
+
+func (s *bls12381Scalar) Sub(x, y group.Scalar) group.Scalar {
+	s.s.Sub(&toBLS12381Scalar(x).s, &toBLS12381Scalar(y).s)
+	return s
+}
+
+func (s *bls12381Scalar) Mul(x, y group.Scalar) group.Scalar {
+	s.s.Mul(&toBLS12381Scalar(x).s, &toBLS12381Scalar(y).s)
+	return s
+}
+
+func (s *bls12381Scalar) Neg(x group.Scalar) group.Scalar {
+	s.s.Set(&toBLS12381Scalar(x).s)
+	s.s.Neg()
+	return s
+}
+
+func (s *bls12381Scalar) Inv(x group.Scalar) group.Scalar {
+	s.s.Inv(&toBLS12381Scalar(x).s)
+	return s
+}
+
+// MarshalBinary returns the scalar in big-endian order
+func (s *bls12381Scalar) MarshalBinary() ([]byte, error) {
+	return s.s.MarshalBinary()
+}
+
+// UnmarshalBinary rejects scalars that are not reduced modulo the order
+func (s *bls12381Scalar) UnmarshalBinary(data []byte) error {
+	if len(data) != bls12381.ScalarSize {
+		return group.ErrUnmarshal
+	}
+	return s.s.UnmarshalBinary(data)
+}
+
+func (s *bls12381Scalar) Marshal(b *cryptobyte.Builder) error {
+	buf, err := s.s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	b.AddBytes(buf)
+	return nil
+}
+
+func (s *bls12381Scalar) Unmarshal(str *cryptobyte.String) bool {
+	var buf [bls12381.ScalarSize]byte
+	return str.CopyBytes(buf[:]) && s.s.UnmarshalBinary(buf[:]) == nil
+}
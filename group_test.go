@@ -0,0 +1,99 @@
+package scheme_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+var groups = []scheme.Group{scheme.BLS12381G1, scheme.P256, scheme.Ristretto255}
+
+func TestGroups(t *testing.T) {
+	for _, g := range groups {
+		t.Run(g.Name(), func(t *testing.T) {
+			n, T := 8, 4
+			mod := scheme.GenerateNumber([]int{256}, n)
+			crt, err := scheme.TryNewCRTSharingWithGroup(g, n, T, mod)
+			assert.NoError(t, err)
+			assert.NoError(t, crt.Validate())
+
+			// Sign with the T2 drones
+			T2 := crt.ThresholdT2
+			Ei := make([]group.Element, 0, T2)
+			Di := make([]group.Element, 0, T2)
+			ei := make([]group.Scalar, 0, T2)
+			di := make([]group.Scalar, 0, T2)
+			for i := 0; i < T2; i++ {
+				ei = append(ei, g.RandomScalar(rand.Reader))
+				di = append(di, g.RandomScalar(rand.Reader))
+				Ei = append(Ei, g.NewElement().MulGen(ei[i]))
+				Di = append(Di, g.NewElement().MulGen(di[i]))
+			}
+			B := scheme.NewB(mod[:T2], Ei, Di)
+
+			m := "Hello World"
+			P := new(bigint.Int).SetInt64(1)
+			signs := make([]*bigint.Int, 0, T2)
+			var R group.Element
+			for i := 0; i < T2; i++ {
+				signer := scheme.NewSigner(ei[i], di[i], crt.Remainder[i], crt.Pub, B[i])
+				s, r, err := signer.Sign(m, crt.Pub, B)
+				assert.NoError(t, err)
+				signs = append(signs, s)
+				R = r
+				P.Mul(P, mod[i])
+			}
+			sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
+			assert.NoError(t, err)
+			assert.True(t, sig.Verify(crt.Pub, m))
+			assert.False(t, sig.Verify(crt.Pub, "Hello World!"))
+			assert.Equal(t, g, sig.Group())
+
+			// Signature encoding
+			data, err := sig.Marshal()
+			assert.NoError(t, err)
+			assert.Len(t, data, scheme.SignatureSize(g))
+			decoded := new(scheme.Signature)
+			assert.NoError(t, decoded.Unmarshal(data))
+			assert.True(t, decoded.Verify(crt.Pub, m))
+
+			// Batch verification
+			failed, err := scheme.VerifyBatch([]string{m, m}, []*scheme.Signature{sig, decoded}, []group.Element{crt.Pub})
+			assert.NoError(t, err)
+			assert.Empty(t, failed)
+
+			// Sharing encoding keeps the group
+			bin, err := crt.MarshalBinary()
+			assert.NoError(t, err)
+			fromBin := new(scheme.CRTSharing)
+			assert.NoError(t, fromBin.UnmarshalBinary(bin))
+			assert.Equal(t, g, fromBin.Group)
+			assert.True(t, crt.Pub.IsEqual(fromBin.Pub))
+
+			js, err := crt.MarshalJSON()
+			assert.NoError(t, err)
+			fromJSON := new(scheme.CRTSharing)
+			assert.NoError(t, fromJSON.UnmarshalJSON(js))
+			assert.Equal(t, g, fromJSON.Group)
+			assert.NoError(t, fromJSON.Validate())
+		})
+	}
+}
+
+func TestGroupByID(t *testing.T) {
+	for _, g := range groups {
+		got, err := scheme.GroupByID(g.ID())
+		assert.NoError(t, err)
+		assert.Equal(t, g, got)
+
+		// Scalars convert to integers below the order
+		x := new(bigint.Int).SetInt64(123456789)
+		assert.Equal(t, 0, g.ScalarToInt(scheme.IntToScalar(g, x)).Cmp(x))
+	}
+	_, err := scheme.GroupByID(0)
+	assert.ErrorIs(t, err, scheme.ErrUnknownGroup)
+}
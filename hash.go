@@ -0,0 +1,133 @@
+package scheme
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cloudflare/circl/expander"
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/xof"
+)
+
+// SuiteID identifies the hash suite a signature was produced with
+type SuiteID byte
+
+const (
+	SuiteSHA256   SuiteID = 1 // expand_message_xmd with SHA-256
+	SuiteSHA512   SuiteID = 2 // expand_message_xmd with SHA-512
+	SuiteSHAKE256 SuiteID = 3 // expand_message_xof with SHAKE256
+)
+
+// Domain separation tags of the hashes used by the scheme
+const (
+	dstPrefix    = "CWTS-V01-"
+	dstRho       = dstPrefix + "rho"
+	dstChallenge = dstPrefix + "challenge"
+	dstNonce     = dstPrefix + "nonce"
+)
+
+var ErrUnknownSuite = errors.New("scheme: unknown hash suite")
+
+// Hasher hashes the binding factor rho and the challenge c into scalars
+type Hasher interface {
+	// ID returns the suite identifier carried in signatures
+	ID() SuiteID
+	// HashToScalar hashes the parts of msg under the domain separation tag dst
+	// into a scalar of the group g. Every part is length prefixed, so the parts
+	// can not be shifted into each other, and the name of the group is appended
+	// to dst.
+	HashToScalar(g Group, dst string, msg ...[]byte) group.Scalar
+	// Prehash hashes the message read from r into the digest signed in
+	// prehash mode
+	Prehash(r io.Reader) ([]byte, error)
+}
+
+type expanderSuite struct {
+	id  SuiteID
+	exp func(dst []byte) expander.Expander
+	pre func(r io.Reader) ([]byte, error)
+}
+
+var (
+	SHA256Suite Hasher = expanderSuite{SuiteSHA256, func(dst []byte) expander.Expander {
+		return expander.NewExpanderMD(crypto.SHA256, dst)
+	}, prehashMD(crypto.SHA256)}
+	SHA512Suite Hasher = expanderSuite{SuiteSHA512, func(dst []byte) expander.Expander {
+		return expander.NewExpanderMD(crypto.SHA512, dst)
+	}, prehashMD(crypto.SHA512)}
+	SHAKE256Suite Hasher = expanderSuite{SuiteSHAKE256, func(dst []byte) expander.Expander {
+		return expander.NewExpanderXOF(xof.SHAKE256, 128, dst)
+	}, prehashXOF(xof.SHAKE256, 64)}
+
+	// DefaultSuite is used by NewSigner and Verify
+	DefaultSuite = SHA256Suite
+)
+
+// SuiteByID returns the suite with the given identifier
+func SuiteByID(id SuiteID) (Hasher, error) {
+	switch id {
+	case SuiteSHA256:
+		return SHA256Suite, nil
+	case SuiteSHA512:
+		return SHA512Suite, nil
+	case SuiteSHAKE256:
+		return SHAKE256Suite, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownSuite, id)
+}
+
+func (s expanderSuite) ID() SuiteID { return s.id }
+
+func (s expanderSuite) Prehash(r io.Reader) ([]byte, error) { return s.pre(r) }
+
+// prehashMD returns the digest of the message under the hash function h
+func prehashMD(h crypto.Hash) func(io.Reader) ([]byte, error) {
+	return func(r io.Reader) ([]byte, error) {
+		d := h.New()
+		if _, err := io.Copy(d, r); err != nil {
+			return nil, err
+		}
+		return d.Sum(nil), nil
+	}
+}
+
+// prehashXOF returns size bytes of the output of the XOF x on the message
+func prehashXOF(x xof.ID, size int) func(io.Reader) ([]byte, error) {
+	return func(r io.Reader) ([]byte, error) {
+		d := x.New()
+		if _, err := io.Copy(d, r); err != nil {
+			return nil, err
+		}
+		digest := make([]byte, size)
+		if _, err := io.ReadFull(d, digest); err != nil {
+			return nil, err
+		}
+		return digest, nil
+	}
+}
+
+func (s expanderSuite) HashToScalar(g Group, dst string, msg ...[]byte) group.Scalar {
+	n := 0
+	for _, m := range msg {
+		n += binary.MaxVarintLen64 + len(m)
+	}
+	in := make([]byte, 0, n)
+	for _, m := range msg {
+		in = binary.AppendUvarint(in, uint64(len(m)))
+		in = append(in, m...)
+	}
+
+	// Hash 128 bits more than the group order, so the reduction is
+	// statistically uniform
+	order := new(big.Int).SetBytes(g.Order())
+	size := uint(order.BitLen()+128+7) / 8
+	buf := s.exp([]byte(dst+"-"+g.Name())).Expand(in, size)
+	x := new(big.Int).SetBytes(buf)
+	return g.NewScalar().SetBigInt(x.Mod(x, order))
+}
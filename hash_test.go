@@ -0,0 +1,45 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuites(t *testing.T) {
+	m := "Hello World"
+	for _, h := range []scheme.Hasher{scheme.SHA256Suite, scheme.SHA512Suite, scheme.SHAKE256Suite} {
+		sig := thresholdSignWith(h, m)
+		assert.Equal(t, h.ID(), sig.Suite)
+		assert.True(t, sig.Verify(crt.Pub, m))
+
+		// The suite is carried in the encoding
+		data, err := sig.Marshal()
+		assert.NoError(t, err)
+		decoded := new(scheme.Signature)
+		assert.NoError(t, decoded.Unmarshal(data))
+		assert.True(t, decoded.Verify(crt.Pub, m))
+
+		// A signature does not verify under another suite
+		for _, other := range []scheme.SuiteID{scheme.SuiteSHA256, scheme.SuiteSHA512, scheme.SuiteSHAKE256} {
+			if other != h.ID() {
+				decoded.Suite = other
+				assert.False(t, decoded.Verify(crt.Pub, m))
+			}
+		}
+	}
+
+	_, err := scheme.SuiteByID(0)
+	assert.ErrorIs(t, err, scheme.ErrUnknownSuite)
+}
+
+func TestHashToScalarDomainSeparation(t *testing.T) {
+	for _, h := range []scheme.Hasher{scheme.SHA256Suite, scheme.SHA512Suite, scheme.SHAKE256Suite} {
+		a := h.HashToScalar(scheme.DefaultGroup, "tag-a", []byte("ab"), []byte("c"))
+		assert.True(t, a.IsEqual(h.HashToScalar(scheme.DefaultGroup, "tag-a", []byte("ab"), []byte("c"))))
+		assert.False(t, a.IsEqual(h.HashToScalar(scheme.DefaultGroup, "tag-b", []byte("ab"), []byte("c"))))
+		// Moving bytes between parts changes the hash
+		assert.False(t, a.IsEqual(h.HashToScalar(scheme.DefaultGroup, "tag-a", []byte("a"), []byte("bc"))))
+	}
+}
@@ -0,0 +1,183 @@
+package scheme
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrKeystore = errors.New("scheme: wrong passphrase or corrupted keystore")
+
+const (
+	keystoreMagic = "CRTK"
+	// KeystoreVersion is the version of the keystore encoding
+	KeystoreVersion = 1
+)
+
+// scrypt parameters of new keystores, N = 2 ** 15 takes about 100 ms
+const (
+	keystoreLogN    = 15
+	keystoreMaxLogN = 20
+	keystoreR       = 8
+	keystoreP       = 1
+)
+
+// Share is the key material a drone keeps across restarts
+type Share struct {
+	ID        string        // Identifier of the drone at the TA
+	Modulus   *bigint.Int   // Modulus
+	Remainder *bigint.Int   // Remainder
+	Pub       group.Element // Group public key
+	Pool      *NoncePool    // Unspent nonce pairs, nil if there are none
+}
+
+// Group returns the group of the public key
+func (s *Share) Group() Group {
+	return groupOf(s.Pub.Group())
+}
+
+// SaveShare writes the share encrypted under the passphrase as
+//
+//	"CRTK" || version || logN || r || p || salt[16] || nonce[12] || ciphertext
+//
+// with AES-256-GCM under the key scrypt(passphrase, salt, 2 ** logN, r, p) and
+// the header as additional data. The plaintext is
+//
+//	group || ID || modulus || remainder || pub || hasPool || pool?
+//
+// where ID and pool are prefixed with their uvarint length.
+func SaveShare(w io.Writer, share *Share, passphrase []byte) error {
+	if share.Modulus == nil || share.Remainder == nil || share.Pub == nil {
+		return fmt.Errorf("%w: missing field", ErrEncoding)
+	}
+	plain := []byte{byte(share.Group().ID())}
+	plain = binary.AppendUvarint(plain, uint64(len(share.ID)))
+	plain = append(plain, share.ID...)
+	plain = appendInt(plain, share.Modulus)
+	plain = appendInt(plain, share.Remainder)
+	plain = append(plain, elementBytes(share.Pub)...)
+	if share.Pool != nil {
+		pool, err := share.Pool.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		plain = append(plain, 1)
+		plain = binary.AppendUvarint(plain, uint64(len(pool)))
+		plain = append(plain, pool...)
+		clear(pool)
+	} else {
+		plain = append(plain, 0)
+	}
+	defer clear(plain)
+
+	header := []byte(keystoreMagic)
+	header = append(header, KeystoreVersion, keystoreLogN, keystoreR, keystoreP)
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	header = append(header, salt...)
+	aead, err := keystoreCipher(passphrase, salt, keystoreLogN, keystoreR, keystoreP)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header = append(header, nonce...)
+	_, err = w.Write(aead.Seal(header, nonce, plain, header))
+	return err
+}
+
+// LoadShare reads a share written by SaveShare, a wrong passphrase fails with ErrKeystore
+func LoadShare(r io.Reader, passphrase []byte) (*Share, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(keystoreMagic)) {
+		return nil, ErrEncodingMagic
+	}
+	h := &reader{buf: data[len(keystoreMagic):]}
+	v := h.byte()
+	logN, R, P := h.byte(), h.byte(), h.byte()
+	salt := h.bytes(16)
+	if h.err != nil {
+		return nil, h.err
+	}
+	if v != KeystoreVersion {
+		return nil, fmt.Errorf("%w: %d", ErrEncodingVersion, v)
+	}
+	if logN == 0 || logN > keystoreMaxLogN || R == 0 || P == 0 {
+		return nil, fmt.Errorf("%w: scrypt parameters", ErrEncoding)
+	}
+	aead, err := keystoreCipher(passphrase, salt, int(logN), int(R), int(P))
+	if err != nil {
+		return nil, err
+	}
+	nonce := h.bytes(aead.NonceSize())
+	if h.err != nil {
+		return nil, h.err
+	}
+	header := data[:len(data)-len(h.buf)]
+	plain, err := aead.Open(nil, nonce, h.buf, header)
+	if err != nil {
+		return nil, ErrKeystore
+	}
+	defer clear(plain)
+
+	p := &reader{buf: plain}
+	g, err := GroupByID(GroupID(p.byte()))
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	share := &Share{}
+	share.ID = string(p.bytes(p.int()))
+	share.Modulus = p.bigInt()
+	share.Remainder = p.bigInt()
+	share.Pub = p.element(g)
+	switch p.byte() {
+	case 0:
+	case 1:
+		share.Pool = NewNoncePool(g)
+		if err := share.Pool.UnmarshalBinary(p.bytes(p.int())); err != nil && p.err == nil {
+			p.fail()
+		}
+	default:
+		p.fail()
+	}
+	if p.err == nil && len(p.buf) != 0 {
+		p.fail()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return share, nil
+}
+
+// keystoreCipher derives the AES-256-GCM key of a keystore from the passphrase
+func keystoreCipher(passphrase, salt []byte, logN, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<logN, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
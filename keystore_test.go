@@ -0,0 +1,58 @@
+package scheme_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystore(t *testing.T) {
+	once()
+	pool := scheme.NewNoncePool(crt.Group)
+	pool.Generate(3)
+	share := &scheme.Share{
+		ID:        "drone-1",
+		Modulus:   moduli[0],
+		Remainder: crt.Remainder[0],
+		Pub:       crt.Pub,
+		Pool:      pool,
+	}
+	passphrase := []byte("correct horse battery staple")
+	buf := new(bytes.Buffer)
+	assert.NoError(t, scheme.SaveShare(buf, share, passphrase))
+	data := buf.Bytes()
+	assert.False(t, bytes.Contains(data, crt.Remainder[0].Bytes()))
+
+	loaded, err := scheme.LoadShare(bytes.NewReader(data), passphrase)
+	assert.NoError(t, err)
+	assert.Equal(t, share.ID, loaded.ID)
+	assert.Equal(t, 0, loaded.Modulus.Cmp(share.Modulus))
+	assert.Equal(t, 0, loaded.Remainder.Cmp(share.Remainder))
+	assert.True(t, loaded.Pub.IsEqual(share.Pub))
+	assert.Equal(t, pool.Commitments(), loaded.Pool.Commitments())
+
+	// Without a pool
+	share.Pool = nil
+	buf.Reset()
+	assert.NoError(t, scheme.SaveShare(buf, share, passphrase))
+	loaded, err = scheme.LoadShare(buf, passphrase)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded.Pool)
+
+	_, err = scheme.LoadShare(bytes.NewReader(data), []byte("wrong"))
+	assert.ErrorIs(t, err, scheme.ErrKeystore)
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	_, err = scheme.LoadShare(bytes.NewReader(tampered), passphrase)
+	assert.ErrorIs(t, err, scheme.ErrKeystore)
+	tampered = bytes.Clone(data)
+	tampered[10] ^= 1
+	_, err = scheme.LoadShare(bytes.NewReader(tampered), passphrase)
+	assert.ErrorIs(t, err, scheme.ErrKeystore)
+	_, err = scheme.LoadShare(bytes.NewReader(data[:20]), passphrase)
+	assert.ErrorIs(t, err, scheme.ErrEncoding)
+	_, err = scheme.LoadShare(bytes.NewReader([]byte("CRTS")), passphrase)
+	assert.ErrorIs(t, err, scheme.ErrEncodingMagic)
+}
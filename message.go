@@ -0,0 +1,89 @@
+package scheme
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrMessageSuite = errors.New("scheme: message was prehashed with another hash suite")
+
+// Flags hashed after the message, so that the signature of a message never
+// verifies as the signature of the digest of another one or in another session
+const (
+	flagPrehash byte = 1 << iota // The message is a digest
+	flagContext                  // The session context follows the flags
+)
+
+// Message is a message as it is hashed into rho and the challenge: either
+// the message itself, or in prehash mode its digest under a hash suite,
+// followed by the flags and the session context if there are any. A
+// prehashed message is only signed and verified with the suite it was hashed
+// with.
+type Message struct {
+	data  []byte          // The message or its digest
+	suite SuiteID         // Suite of the digest, 0 if the message is not prehashed
+	ctx   *SessionContext // Session the message is signed in, nil if there is none
+}
+
+// NewMessage returns the message m itself, m is not copied and must not be
+// modified while it is in use
+func NewMessage(m []byte) Message {
+	return Message{data: m}
+}
+
+// PrehashMessage reads the message from r and returns its digest under the
+// hash suite, the message is never held in memory as a whole
+func PrehashMessage(h Hasher, r io.Reader) (Message, error) {
+	digest, err := h.Prehash(r)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{data: digest, suite: h.ID()}, nil
+}
+
+// WithContext returns the message bound to the signing session, the drones
+// and the verifier must all use the same context
+func (msg Message) WithContext(ctx *SessionContext) Message {
+	msg.ctx = ctx
+	return msg
+}
+
+// Prehashed reports whether the message is a digest
+func (msg Message) Prehashed() bool {
+	return msg.suite != 0
+}
+
+// Context returns the session context of the message, or nil if there is none
+func (msg Message) Context() *SessionContext {
+	return msg.ctx
+}
+
+// parts returns the hash input of the message: the message or its digest,
+// the flags and the encoded session context. A message that is neither
+// prehashed nor bound to a session is hashed as a single part like before
+// the flags existed.
+func (msg Message) parts() [][]byte {
+	var flags byte
+	if msg.Prehashed() {
+		flags |= flagPrehash
+	}
+	if msg.ctx != nil {
+		flags |= flagContext
+	}
+	if flags == 0 {
+		return [][]byte{msg.data}
+	}
+	parts := [][]byte{msg.data, {flags}}
+	if msg.ctx != nil {
+		parts = append(parts, msg.ctx.encode())
+	}
+	return parts
+}
+
+// check returns an error if the message was prehashed with another suite than h
+func (msg Message) check(h Hasher) error {
+	if msg.Prehashed() && msg.suite != h.ID() {
+		return ErrMessageSuite
+	}
+	return nil
+}
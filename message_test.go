@@ -0,0 +1,108 @@
+package scheme_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+// signMessage signs msg with the first ThresholdT2 drones using the hash suite h
+// and checks their partial signatures
+func signMessage(t *testing.T, h scheme.Hasher, sign func(*scheme.Signer, scheme.B) (*bigint.Int, group.Element, error), msg scheme.Message) *scheme.Signature {
+	once()
+	T := crt.ThresholdT2
+	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
+
+	P := new(bigint.Int).SetInt64(1)
+	signs := make([]*bigint.Int, 0, T)
+	R := make([]group.Element, 0, T)
+	for i := 0; i < T; i++ {
+		signer := scheme.NewSigner(ei[i], di[i], crt.Remainder[i], crt.Pub, B[i])
+		signer.Suite = h
+		s, r, err := sign(signer, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		R = append(R, r)
+		P.Mul(P, moduli[i])
+	}
+	U, err := crt.SigningCommitments(moduli[:T])
+	assert.NoError(t, err)
+	failed, err := scheme.VerifyPartialsMessage(h, msg, signs, R, U, crt.Pub, B)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	sig, err := scheme.AggregateSignature(h, msg, signs, R[0], crt.Pub, P)
+	assert.NoError(t, err)
+	return sig
+}
+
+func TestSignBytes(t *testing.T) {
+	m := []byte("Hello World")
+	sig := signMessage(t, scheme.DefaultSuite, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+		return s.SignBytes(m, crt.Pub, B)
+	}, scheme.NewMessage(m))
+	assert.True(t, sig.VerifyBytes(crt.Pub, m))
+	// A binary message is signed like the same string
+	assert.True(t, sig.Verify(crt.Pub, string(m)))
+	assert.False(t, sig.VerifyBytes(crt.Pub, []byte("Hello World!")))
+}
+
+func TestSignReader(t *testing.T) {
+	log := make([]byte, 3<<20)
+	rand.Read(log)
+	for _, h := range []scheme.Hasher{scheme.SHA256Suite, scheme.SHA512Suite, scheme.SHAKE256Suite} {
+		msg, err := scheme.PrehashMessage(h, bytes.NewReader(log))
+		assert.NoError(t, err)
+		assert.True(t, msg.Prehashed())
+		sig := signMessage(t, h, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+			return s.SignReader(bytes.NewReader(log), crt.Pub, B)
+		}, msg)
+
+		ok, err := sig.VerifyReader(crt.Pub, bytes.NewReader(log))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, sig.VerifyMessage(crt.Pub, msg))
+		log[0] ^= 1
+		ok, _ = sig.VerifyReader(crt.Pub, bytes.NewReader(log))
+		assert.False(t, ok)
+		log[0] ^= 1
+
+		// The flag separates the prehashed message from its digest signed as is
+		digest, err := h.Prehash(bytes.NewReader(log))
+		assert.NoError(t, err)
+		assert.False(t, sig.VerifyBytes(crt.Pub, digest))
+		raw := signMessage(t, h, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+			return s.SignBytes(digest, crt.Pub, B)
+		}, scheme.NewMessage(digest))
+		assert.False(t, raw.VerifyMessage(crt.Pub, msg))
+	}
+
+	// A digest is only used with its own suite
+	msg, _ := scheme.PrehashMessage(scheme.SHA512Suite, bytes.NewReader(log))
+	sig := signMessage(t, scheme.SHA512Suite, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+		return s.SignMessage(msg, crt.Pub, B)
+	}, msg)
+	sig.Suite = scheme.SuiteSHA256
+	assert.False(t, sig.VerifyMessage(crt.Pub, msg))
+	T := crt.ThresholdT2
+	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
+	signer := scheme.NewSigner(ei[0], di[0], crt.Remainder[0], crt.Pub, B[0])
+	_, _, err := signer.SignMessage(msg, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrMessageSuite)
+
+	// Read errors are returned
+	broken := io.MultiReader(bytes.NewReader(log[:1024]), iotest.ErrReader(errRead))
+	_, _, err = signer.SignReader(broken, crt.Pub, B)
+	assert.ErrorIs(t, err, errRead)
+	_, err = sig.VerifyReader(crt.Pub, io.MultiReader(bytes.NewReader(log[:1024]), iotest.ErrReader(errRead)))
+	assert.ErrorIs(t, err, errRead)
+}
+
+var errRead = errors.New("read error")
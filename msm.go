@@ -0,0 +1,79 @@
+package scheme
+
+import (
+	"math/bits"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+// multiScalarMult returns sum(scalars[i] * points[i]) using the bucket method
+// of Pippenger, which needs far fewer group operations than computing every
+// product separately once there are more than a handful of points.
+func multiScalarMult(g Group, scalars []group.Scalar, points []group.Element) group.Element {
+	res := g.Identity()
+	n := len(points)
+	if n == 0 {
+		return res
+	}
+
+	// Big-endian bytes of every scalar
+	size := int(g.Params().ScalarLength)
+	ks := make([][]byte, 0, n)
+	for _, k := range scalars {
+		x := g.ScalarToInt(k)
+		b := make([]byte, size)
+		ks = append(ks, append(b[:size-len(x.Bytes())], x.Bytes()...))
+		bigint.Clear(x)
+	}
+
+	// Window size, roughly log2(n) bits per window
+	c := max(bits.Len(uint(n))-2, 2)
+	c = min(c, 16)
+	nbits := size * 8
+	windows := (nbits + c - 1) / c
+
+	buckets := make([]group.Element, 1<<c)
+	for i := range buckets {
+		buckets[i] = g.Identity()
+	}
+	identity := g.Identity()
+	sum := g.Identity()
+	for w := windows - 1; w >= 0; w-- {
+		for i := 0; i < c; i++ {
+			res.Dbl(res)
+		}
+
+		for i := range buckets {
+			buckets[i].Set(identity)
+		}
+		for i := range points {
+			if d := window(ks[i], w*c, c); d != 0 {
+				buckets[d].Add(buckets[d], points[i])
+			}
+		}
+
+		// sum_{d} d * bucket[d] = sum_{d} (bucket[d] + ... + bucket[max])
+		sum.Set(identity)
+		for d := len(buckets) - 1; d > 0; d-- {
+			sum.Add(sum, buckets[d])
+			res.Add(res, sum)
+		}
+	}
+	return res
+}
+
+// window returns the c bits of the big-endian integer k starting at bit offset
+// (counted from the least significant bit)
+func window(k []byte, offset, c int) int {
+	d := 0
+	for i := c - 1; i >= 0; i-- {
+		pos := offset + i
+		byteIdx := len(k) - 1 - pos/8
+		d <<= 1
+		if byteIdx >= 0 {
+			d |= int(k[byteIdx]>>(pos%8)) & 1
+		}
+	}
+	return d
+}
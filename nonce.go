@@ -0,0 +1,237 @@
+package scheme
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrNonceSpent    = errors.New("scheme: nonce is spent or unknown")
+	ErrNonceMismatch = errors.New("scheme: nonce commitments do not match the pool")
+	ErrSignerNotInB  = errors.New("scheme: signer is not part of B")
+)
+
+// NonceCommitment is the public part (D, E) = (d * G, e * G) of a nonce pair,
+// the Index identifies the pair in the pool of the drone
+type NonceCommitment struct {
+	Index uint64
+	D     group.Element
+	E     group.Element
+}
+
+type noncePair struct {
+	d group.Scalar
+	e group.Scalar
+}
+
+// NoncePool holds the preprocessed nonce pairs (d_j, e_j) of a drone.
+// Every pair signs at most once: it is deleted from the pool when it is used,
+// and indices are never handed out twice. Save the pool with MarshalBinary
+// after every signature, a pool restored from an older state would reuse the
+// nonces spent in between.
+type NoncePool struct {
+	group    Group
+	next     uint64 // Index of the next generated pair
+	nonces   map[uint64]noncePair
+	sessions map[string]bool // Sessions the drone committed to
+	mux      sync.Mutex
+}
+
+// NewNoncePool creates an empty pool in the group g
+func NewNoncePool(g Group) *NoncePool {
+	return &NoncePool{group: g, nonces: make(map[uint64]noncePair), sessions: make(map[string]bool)}
+}
+
+// UseSession records the session and reports false if it was recorded before.
+// A pair derived with DeriveNonce is only fresh for a new session, so the
+// drone records the session and saves the pool before the commitment of the
+// pair leaves it.
+func (np *NoncePool) UseSession(session []byte) bool {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	if np.sessions[string(session)] {
+		return false
+	}
+	np.sessions[string(session)] = true
+	return true
+}
+
+// Generate adds n fresh nonce pairs to the pool and returns their commitments,
+// which the drone publishes to the aggregator ahead of the signing rounds
+func (np *NoncePool) Generate(n int) []NonceCommitment {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	g := np.group
+	commitments := make([]NonceCommitment, 0, n)
+	for i := 0; i < n; i++ {
+		commitments = append(commitments, np.add(g.RandomNonZeroScalar(rand.Reader), g.RandomNonZeroScalar(rand.Reader)))
+	}
+	return commitments
+}
+
+// add adds the pair (d, e) under the next index, the caller holds the lock
+func (np *NoncePool) add(d, e group.Scalar) NonceCommitment {
+	g := np.group
+	np.nonces[np.next] = noncePair{d: d, e: e}
+	c := NonceCommitment{
+		Index: np.next,
+		D:     g.NewElement().MulGen(d),
+		E:     g.NewElement().MulGen(e),
+	}
+	np.next++
+	return c
+}
+
+// Commitments returns the commitments of the unspent nonce pairs by index
+func (np *NoncePool) Commitments() []NonceCommitment {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	g := np.group
+	commitments := make([]NonceCommitment, 0, len(np.nonces))
+	for i, pair := range np.nonces {
+		commitments = append(commitments, NonceCommitment{
+			Index: i,
+			D:     g.NewElement().MulGen(pair.d),
+			E:     g.NewElement().MulGen(pair.e),
+		})
+	}
+	slices.SortFunc(commitments, func(x, y NonceCommitment) int { return cmp.Compare(x.Index, y.Index) })
+	return commitments
+}
+
+// Len returns the number of unspent nonce pairs
+func (np *NoncePool) Len() int {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	return len(np.nonces)
+}
+
+// take removes the pair of the item from the pool and returns it, the pair
+// is spent even if its commitments do not match the item
+func (np *NoncePool) take(item BItem) (d, e group.Scalar, err error) {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	pair, ok := np.nonces[item.Nonce]
+	if !ok {
+		return nil, nil, ErrNonceSpent
+	}
+	delete(np.nonces, item.Nonce)
+	g := np.group
+	if !sameGroup(g, item.D, item.E) ||
+		!g.NewElement().MulGen(pair.d).IsEqual(item.D) || !g.NewElement().MulGen(pair.e).IsEqual(item.E) {
+		pair.d.SetUint64(0)
+		pair.e.SetUint64(0)
+		return nil, nil, ErrNonceMismatch
+	}
+	return pair.d, pair.e, nil
+}
+
+// MarshalBinary encodes the pool as
+//
+//	group || next || count || (index || d || e)[count] || sessions || session[sessions]
+//
+// with the unspent pairs in ascending order of their uvarint index and the
+// recorded sessions in ascending order, prefixed with their uvarint length.
+// The encoding holds secret nonces and must be stored like the remainder.
+func (np *NoncePool) MarshalBinary() ([]byte, error) {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	indices := make([]uint64, 0, len(np.nonces))
+	for i := range np.nonces {
+		indices = append(indices, i)
+	}
+	slices.Sort(indices)
+	buf := []byte{byte(np.group.ID())}
+	buf = binary.AppendUvarint(buf, np.next)
+	buf = binary.AppendUvarint(buf, uint64(len(indices)))
+	for _, i := range indices {
+		d, err := np.nonces[i].d.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		e, err := np.nonces[i].e.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, i)
+		buf = append(buf, d...)
+		buf = append(buf, e...)
+	}
+	sessions := slices.Sorted(maps.Keys(np.sessions))
+	buf = binary.AppendUvarint(buf, uint64(len(sessions)))
+	for _, session := range sessions {
+		buf = binary.AppendUvarint(buf, uint64(len(session)))
+		buf = append(buf, session...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a pool produced by MarshalBinary, a pool encoded
+// without the sessions has none recorded
+func (np *NoncePool) UnmarshalBinary(data []byte) error {
+	r := &reader{buf: data}
+	g, err := GroupByID(GroupID(r.byte()))
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return err
+	}
+	next := r.uvarint()
+	n := r.int()
+	if r.err == nil && n > len(r.buf) {
+		r.fail()
+	}
+	nonces := make(map[uint64]noncePair, n)
+	for j := 0; j < n && r.err == nil; j++ {
+		i := r.uvarint()
+		d, e := r.scalar(g), r.scalar(g)
+		if _, dup := nonces[i]; dup || i >= next {
+			r.fail()
+		}
+		nonces[i] = noncePair{d: d, e: e}
+	}
+	sessions := make(map[string]bool)
+	if r.err == nil && len(r.buf) != 0 {
+		n := r.int()
+		if r.err == nil && n > len(r.buf) {
+			r.fail()
+		}
+		for j := 0; j < n && r.err == nil; j++ {
+			session := r.bytes(r.int())
+			if sessions[string(session)] {
+				r.fail()
+			}
+			sessions[string(session)] = true
+		}
+	}
+	if r.err == nil && len(r.buf) != 0 {
+		r.fail()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	np.mux.Lock()
+	np.group, np.next, np.nonces, np.sessions = g, next, nonces, sessions
+	np.mux.Unlock()
+	return nil
+}
+
+// scalar reads a scalar of the group g
+func (r *reader) scalar(g Group) group.Scalar {
+	b := r.bytes(int(g.Params().ScalarLength))
+	s := g.NewScalar()
+	if r.err == nil {
+		if err := s.UnmarshalBinary(b); err != nil {
+			r.fail()
+		}
+	}
+	return s
+}
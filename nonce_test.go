@@ -0,0 +1,104 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoncePool(t *testing.T) {
+	once()
+	T := crt.ThresholdT2
+	pools := make([]*scheme.NoncePool, 0, T)
+	signers := make([]*scheme.Signer, 0, T)
+	commitments := make([][]scheme.NonceCommitment, 0, T)
+	P := new(bigint.Int).SetInt64(1)
+	for i := 0; i < T; i++ {
+		pool := scheme.NewNoncePool(crt.Group)
+		pools = append(pools, pool)
+		commitments = append(commitments, pool.Generate(2))
+		signers = append(signers, scheme.NewPoolSigner(pool, crt.Remainder[i], crt.Pub, moduli[i]))
+		P.Mul(P, moduli[i])
+	}
+
+	// Every round uses the next nonce of every drone
+	round := func(j int) scheme.B {
+		B := make(scheme.B, 0, T)
+		for i := 0; i < T; i++ {
+			c := commitments[i][j]
+			B = append(B, scheme.BItem{P: moduli[i], E: c.E, D: c.D, Nonce: c.Index})
+		}
+		return B
+	}
+	for j := 0; j < 2; j++ {
+		m := "Hello World"
+		B := round(j)
+		signs := make([]*bigint.Int, 0, T)
+		var R group.Element
+		for _, signer := range signers {
+			s, r, err := signer.Sign(m, crt.Pub, B)
+			assert.NoError(t, err)
+			signs = append(signs, s)
+			R = r
+		}
+		sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
+		assert.NoError(t, err)
+		assert.True(t, sig.Verify(crt.Pub, m))
+
+		// A spent nonce signs no more
+		_, _, err = signers[0].Sign(m, crt.Pub, B)
+		assert.ErrorIs(t, err, scheme.ErrNonceSpent)
+	}
+	assert.Equal(t, 0, pools[0].Len())
+
+	// Commitments that do not match the pool spend the nonce without signing
+	c := pools[0].Generate(2)
+	B := round(0)
+	B[0] = scheme.BItem{P: moduli[0], E: c[1].E, D: c[1].D, Nonce: c[0].Index}
+	_, _, err := signers[0].Sign("Hello World", crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrNonceMismatch)
+	assert.Equal(t, 1, pools[0].Len())
+
+	// A drone outside B does not sign
+	_, _, err = signers[0].Sign("Hello World", crt.Pub, B[1:])
+	assert.ErrorIs(t, err, scheme.ErrSignerNotInB)
+
+	// A modulus repeated in B leaves the drone without a coefficient
+	c = pools[0].Generate(1)
+	B = round(0)
+	B[0] = scheme.BItem{P: moduli[0], E: c[0].E, D: c[0].D, Nonce: c[0].Index}
+	B[1].P = moduli[0]
+	_, _, err = signers[0].Sign("Hello World", crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrModuliNotCoprime)
+	assert.Equal(t, 2, pools[0].Len())
+}
+
+func TestNoncePoolBinary(t *testing.T) {
+	pool := scheme.NewNoncePool(scheme.P256)
+	c := pool.Generate(3)
+	assert.True(t, pool.UseSession([]byte("session")))
+	assert.False(t, pool.UseSession([]byte("session")))
+	data, err := pool.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := new(scheme.NoncePool)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, 3, decoded.Len())
+	restored := decoded.Commitments()
+	for i := range c {
+		assert.Equal(t, c[i].Index, restored[i].Index)
+		assert.True(t, c[i].D.IsEqual(restored[i].D))
+		assert.True(t, c[i].E.IsEqual(restored[i].E))
+	}
+
+	// New nonces never reuse an index of the saved pool, nor a session
+	assert.Equal(t, uint64(3), decoded.Generate(1)[0].Index)
+	assert.False(t, decoded.UseSession([]byte("session")))
+	assert.True(t, decoded.UseSession([]byte("other")))
+
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), scheme.ErrEncoding)
+}
@@ -0,0 +1,288 @@
+package scheme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+// Magic bytes at the start of binary encoded GroupParams
+const paramsMagic = "CRTP"
+
+// GroupParamsVersion is the version of the GroupParams encoding,
+// version 2 adds the number of dealers, version 1 has a single dealer
+const GroupParamsVersion byte = 2
+
+// GroupParams are the public parameters of a sharing. They hold neither the
+// secret nor any remainder and are safe to publish to verifiers and aggregators.
+type GroupParams struct {
+	N           int             // Number of parties
+	ThresholdT1 int             // The minimum number of participants required to recover the secret.
+	ThresholdT2 int             // The minimum number of participants required for threshold signatures, ThresholdT1 as the partial signatures aggregate once the product exceeds S
+	Thresholdt  int             // The maximum number of participants who cannot recover the secret.
+	Dealers     int             // Number of dealings adding up to the secret, 1 for a dealer and N for a key generation
+	Weight      []int           // The weight of each participant.
+	Moduli      []*bigint.Int   // The modulus of each participant.
+	PMin1       *bigint.Int     // The modular product of the minimum number of participants required to recover the secret.
+	PMin2       *bigint.Int     // The modular product of the minimum number of participants required for threshold signatures.
+	PMax        *bigint.Int     // The modular product of the maximum number of participants who cannot recover the secret.
+	Pub         group.Element   // The public key
+	Group       Group           // The group the public key belongs to
+	Commitments []group.Element // The share commitments Y_i = r_i * G, nil if unknown
+	Revoked     []bool          // Whether each participant is revoked, nil if none is
+}
+
+// Params returns a copy of the public parameters of the sharing,
+// later changes to the sharing do not affect it
+func (crt *CRTSharing) Params() *GroupParams {
+	return crt.GroupParams.Clone()
+}
+
+// Clone returns a deep copy of the parameters
+func (gp *GroupParams) Clone() *GroupParams {
+	c := *gp
+	c.Weight = slices.Clone(gp.Weight)
+	c.Moduli = copyInts(gp.Moduli)
+	c.PMin1 = copyInt(gp.PMin1)
+	c.PMin2 = copyInt(gp.PMin2)
+	c.PMax = copyInt(gp.PMax)
+	if gp.Pub != nil {
+		c.Pub = gp.Pub.Copy()
+	}
+	if gp.Commitments != nil {
+		c.Commitments = make([]group.Element, 0, len(gp.Commitments))
+		for _, Y := range gp.Commitments {
+			c.Commitments = append(c.Commitments, Y.Copy())
+		}
+	}
+	c.Revoked = slices.Clone(gp.Revoked)
+	return &c
+}
+
+// Validate checks the invariants of the parameters: the moduli are sorted and
+// pairwise coprime, the thresholds and products match the moduli,
+// (L+1) * p < PMin1 <= PMin2, and the public key and the share commitments
+// are in the group.
+func (gp *GroupParams) Validate() error {
+	n := gp.N
+	if n <= 0 || n != len(gp.Moduli) {
+		return fmt.Errorf("%w: n = %d, len(moduli) = %d", ErrPartyCount, n, len(gp.Moduli))
+	}
+	if len(gp.Weight) != n {
+		return fmt.Errorf("%w: weight = %d, moduli = %d", ErrLengthMismatch, len(gp.Weight), n)
+	}
+	if gp.Thresholdt <= 0 || gp.Thresholdt > n {
+		return fmt.Errorf("%w: t = %d, n = %d", ErrThreshold, gp.Thresholdt, n)
+	}
+	if gp.Dealers <= 0 || gp.Dealers > n {
+		return fmt.Errorf("%w: dealers = %d, n = %d", ErrDealers, gp.Dealers, n)
+	}
+	if err := checkModuli(gp.Moduli); err != nil {
+		return err
+	}
+	for i, g := range gp.Moduli {
+		if gp.Weight[i] != g.BitLen() {
+			return fmt.Errorf("%w: index %d", ErrWeightMismatch, i)
+		}
+	}
+
+	if gp.Revoked != nil && len(gp.Revoked) != n {
+		return fmt.Errorf("%w: revoked = %d, moduli = %d", ErrLengthMismatch, len(gp.Revoked), n)
+	}
+
+	// Recompute the products from the moduli, revoked moduli can not take part
+	// in recovering or signing
+	active := gp.ActiveModuli()
+	if gp.ThresholdT1 <= 0 || gp.ThresholdT1 > gp.ThresholdT2 || gp.ThresholdT2 > len(active) {
+		return fmt.Errorf("%w: T1 = %d, T2 = %d", ErrThresholdMismatch, gp.ThresholdT1, gp.ThresholdT2)
+	}
+	pMax := productMax(gp.Moduli, gp.Thresholdt)
+	defer bigint.Clear(pMax)
+	pMin1 := product(active[:gp.ThresholdT1])
+	defer bigint.Clear(pMin1)
+	pMin2 := product(active[:gp.ThresholdT2])
+	defer bigint.Clear(pMin2)
+	if gp.PMax == nil || gp.PMin1 == nil || gp.PMin2 == nil ||
+		pMax.Cmp(gp.PMax) != 0 || pMin1.Cmp(gp.PMin1) != 0 || pMin2.Cmp(gp.PMin2) != 0 {
+		return ErrThresholdMismatch
+	}
+
+	// (L+1) * p < PMin1 <= PMin2
+	if gp.Group == nil {
+		return ErrUnknownGroup
+	}
+	left := gp.RecoveryBound()
+	defer bigint.Clear(left)
+	if gp.PMin1.Cmp(left) != 1 {
+		return ErrPMin1Boundary
+	}
+	if gp.PMin2.Cmp(gp.PMin1) == -1 {
+		return ErrPMin2Boundary
+	}
+
+	if !sameGroup(gp.Group, gp.Pub) {
+		return ErrPublicKeyMismatch
+	}
+	if gp.Commitments == nil {
+		return nil
+	}
+	if len(gp.Commitments) != n {
+		return fmt.Errorf("%w: %d commitments for %d parties", ErrCommitmentMismatch, len(gp.Commitments), n)
+	}
+	for i, Y := range gp.Commitments {
+		if !sameGroup(gp.Group, Y) {
+			return fmt.Errorf("%w: index %d", ErrCommitmentMismatch, i)
+		}
+	}
+	return nil
+}
+
+// Commitment returns the share commitment of the modulus,
+// or nil if it is unknown
+func (gp *GroupParams) Commitment(modulus *bigint.Int) group.Element {
+	i := gp.index(modulus)
+	if i < 0 || i >= len(gp.Commitments) {
+		return nil
+	}
+	return gp.Commitments[i]
+}
+
+// MarshalBinary encodes the parameters as
+//
+//	"CRTP" || version || group || N || T1 || T2 || t || dealers || weight[N] || moduli[N] ||
+//	PMin1 || PMin2 || PMax || pub || hasCommitments || commitments[N]? || revoked
+//
+// with the integers and the revoked moduli encoded as in CRTSharing.MarshalBinary
+// and compressed group elements.
+func (gp *GroupParams) MarshalBinary() ([]byte, error) {
+	if len(gp.Weight) != gp.N || len(gp.Moduli) != gp.N ||
+		(gp.Commitments != nil && len(gp.Commitments) != gp.N) ||
+		(gp.Revoked != nil && len(gp.Revoked) != gp.N) {
+		return nil, ErrLengthMismatch
+	}
+	if gp.PMin1 == nil || gp.PMin2 == nil || gp.PMax == nil || !sameGroup(gp.Group, gp.Pub) ||
+		(gp.Commitments != nil && !sameGroup(gp.Group, gp.Commitments...)) {
+		return nil, fmt.Errorf("%w: missing field", ErrEncoding)
+	}
+
+	buf := make([]byte, 0, 64+gp.N*(gp.PMax.BitLen()/8+2))
+	buf = append(buf, paramsMagic...)
+	buf = append(buf, GroupParamsVersion, byte(gp.Group.ID()))
+	buf = binary.AppendUvarint(buf, uint64(gp.N))
+	buf = binary.AppendUvarint(buf, uint64(gp.ThresholdT1))
+	buf = binary.AppendUvarint(buf, uint64(gp.ThresholdT2))
+	buf = binary.AppendUvarint(buf, uint64(gp.Thresholdt))
+	buf = binary.AppendUvarint(buf, uint64(gp.Dealers))
+	for _, w := range gp.Weight {
+		buf = binary.AppendUvarint(buf, uint64(w))
+	}
+	for _, g := range gp.Moduli {
+		buf = appendInt(buf, g)
+	}
+	buf = appendInt(buf, gp.PMin1)
+	buf = appendInt(buf, gp.PMin2)
+	buf = appendInt(buf, gp.PMax)
+	buf = append(buf, elementBytes(gp.Pub)...)
+	if gp.Commitments != nil {
+		buf = append(buf, 1)
+		for _, Y := range gp.Commitments {
+			buf = append(buf, elementBytes(Y)...)
+		}
+	} else {
+		buf = append(buf, 0)
+	}
+	return appendRevoked(buf, gp.Revoked), nil
+}
+
+// UnmarshalBinary decodes parameters produced by MarshalBinary.
+// It only checks the structure of the encoding, call Validate to check the
+// invariants of the decoded parameters.
+func (gp *GroupParams) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(paramsMagic)) {
+		return ErrEncodingMagic
+	}
+	r := &reader{buf: data[len(paramsMagic):]}
+	var c GroupParams
+	v := r.byte()
+	if r.err != nil {
+		return r.err
+	}
+	if v != 1 && v != GroupParamsVersion {
+		return fmt.Errorf("%w: %d", ErrEncodingVersion, v)
+	}
+	g, err := GroupByID(GroupID(r.byte()))
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return err
+	}
+	c.Group = g
+
+	c.N = r.int()
+	c.ThresholdT1 = r.int()
+	c.ThresholdT2 = r.int()
+	c.Thresholdt = r.int()
+	c.Dealers = 1
+	if v == GroupParamsVersion {
+		c.Dealers = r.int()
+	}
+	// Every party needs at least two bytes, reject absurd lengths early
+	if r.err == nil && c.N > len(r.buf)/2 {
+		return fmt.Errorf("%w: N = %d", ErrEncoding, c.N)
+	}
+	c.Weight = make([]int, 0, c.N)
+	for i := 0; i < c.N; i++ {
+		c.Weight = append(c.Weight, r.int())
+	}
+	c.Moduli = make([]*bigint.Int, 0, c.N)
+	for i := 0; i < c.N; i++ {
+		c.Moduli = append(c.Moduli, r.bigInt())
+	}
+	c.PMin1 = r.bigInt()
+	c.PMin2 = r.bigInt()
+	c.PMax = r.bigInt()
+	c.Pub = r.element(g)
+	switch r.byte() {
+	case 0:
+	case 1:
+		c.Commitments = make([]group.Element, 0, c.N)
+		for i := 0; i < c.N && r.err == nil; i++ {
+			c.Commitments = append(c.Commitments, r.element(g))
+		}
+	default:
+		r.fail()
+	}
+	c.Revoked = r.revoked(c.N)
+	if r.err == nil && len(r.buf) != 0 {
+		r.fail()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*gp = c
+	return nil
+}
+
+// copyInt returns a copy of x, or nil if x is nil
+func copyInt(x *bigint.Int) *bigint.Int {
+	if x == nil {
+		return nil
+	}
+	return new(bigint.Int).Set(x)
+}
+
+func copyInts(xs []*bigint.Int) []*bigint.Int {
+	if xs == nil {
+		return nil
+	}
+	res := make([]*bigint.Int, 0, len(xs))
+	for _, x := range xs {
+		res = append(res, copyInt(x))
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupParams(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 12)
+	sharing, err := scheme.TryNewCRTSharing(12, 4, mod)
+	assert.NoError(t, err)
+	params := sharing.Params()
+	assert.NoError(t, params.Validate())
+	assert.True(t, sharing.Pub.IsEqual(params.Pub))
+	assert.True(t, sharing.Commitments[3].IsEqual(params.Commitment(mod[3])))
+
+	// The public bound is PMin2, the exact one of the dealer is below it
+	T2 := sharing.ThresholdT2
+	ok, _ := params.CanSign(mod[:T2])
+	assert.True(t, ok)
+	ok, _ = params.CanSign(mod[1:T2])
+	assert.False(t, ok)
+	ok, _ = sharing.CanSign(mod[:T2])
+	assert.True(t, ok)
+
+	// The copy does not follow the dealer
+	assert.NoError(t, sharing.Revoke(mod[0]))
+	assert.False(t, params.IsRevoked(mod[0]))
+	assert.NoError(t, params.Validate())
+	assert.NoError(t, sharing.Params().Validate())
+	assert.True(t, sharing.Params().IsRevoked(mod[0]))
+
+	// Invalid parameters are rejected
+	bad := params.Clone()
+	bad.ThresholdT2--
+	assert.ErrorIs(t, bad.Validate(), scheme.ErrThresholdMismatch)
+	bad = params.Clone()
+	bad.Commitments = bad.Commitments[1:]
+	assert.ErrorIs(t, bad.Validate(), scheme.ErrCommitmentMismatch)
+	bad = params.Clone()
+	bad.Pub = nil
+	assert.ErrorIs(t, bad.Validate(), scheme.ErrPublicKeyMismatch)
+}
+
+func TestGroupParamsBinary(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 12)
+	sharing, err := scheme.TryNewCRTSharing(12, 4, mod)
+	assert.NoError(t, err)
+	assert.NoError(t, sharing.Revoke(mod[2]))
+	params := sharing.Params()
+	data, err := params.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := new(scheme.GroupParams)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, decoded.Validate())
+	assert.True(t, decoded.IsRevoked(mod[2]))
+	assert.True(t, params.Commitments[5].IsEqual(decoded.Commitments[5]))
+
+	again, err := decoded.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	// Without the share commitments
+	decoded.Commitments = nil
+	data, err = decoded.MarshalBinary()
+	assert.NoError(t, err)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Nil(t, decoded.Commitments)
+	assert.NoError(t, decoded.Validate())
+
+	// Truncated, trailing and unknown version encodings are rejected
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[1:]), scheme.ErrEncodingMagic)
+	bad := append([]byte{}, data...)
+	bad[4] = 0xff
+	assert.ErrorIs(t, decoded.UnmarshalBinary(bad), scheme.ErrEncodingVersion)
+
+	// The encoding of the sharing is not the one of the parameters
+	data, _ = sharing.MarshalBinary()
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data), scheme.ErrEncodingMagic)
+}
@@ -0,0 +1,122 @@
+package scheme
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrPartialLength    = errors.New("scheme: partial signatures, commitments and B differ in length")
+	ErrPartialSignature = errors.New("scheme: invalid partial signature")
+	ErrAggregate        = errors.New("scheme: partial signatures do not aggregate to a valid signature")
+)
+
+// VerifyPartial checks the partial signature (s, R) of the i-th drone of B on m
+// against its nonce commitments E_i, D_i and its signing commitment U = u_i * G
+// from SigningCommitments.
+//
+// An honest drone returns s = k + c * u_i mod q with k = d_i + e_i * rho and
+// u_i = lambda_i * r_i mod P, so s * G = K_i + c * U is checked in the
+// exponent. The nonce k blinds u_i, but U = c^-1 * (s * G - K_i) is public
+// with every partial signature: the remainder of a modulus small enough to
+// search, about sqrt(m_i) steps, is recovered from it. Moduli should have at
+// least 256 bits.
+func VerifyPartial(h Hasher, m string, s *bigint.Int, R group.Element, U group.Element, pub group.Element, B B, i int) bool {
+	return verifyPartial(h, NewMessage([]byte(m)), s, R, U, pub, B, i)
+}
+
+// verifyPartial is VerifyPartial with a message that may be prehashed
+func verifyPartial(h Hasher, msg Message, s *bigint.Int, R group.Element, U group.Element, pub group.Element, B B, i int) bool {
+	g := groupOf(pub.Group())
+	if i < 0 || i >= len(B) || s == nil || s.Sign() < 0 || !sameGroup(g, R, U) || msg.check(h) != nil {
+		return false
+	}
+	q := groupOrder(g)
+	defer bigint.Clear(q)
+	if s.Cmp(q) >= 0 {
+		return false
+	}
+	item := B[i]
+
+	// R must be the commitment of B
+	rho := item.rho(h, msg, pub, B)
+	if !R.IsEqual(B.commitment(rho)) {
+		return false
+	}
+
+	// s * G = D_i + rho * E_i + c * U
+	right := g.NewElement().Mul(item.E, rho)
+	right.Add(right, item.D)
+	cU := g.NewElement().Mul(U, challenge(h, msg, R, pub))
+	right.Add(right, cU)
+	return g.NewElement().MulGen(IntToScalar(g, s)).IsEqual(right)
+}
+
+// SigningCommitments returns the commitments U_i = u_i * G to the shares the
+// drones with the moduli sign with, u_i = lambda_i * r_i mod P where P is the
+// product of the moduli. They depend on the signing set and the remainders,
+// so only the dealer computes them. The moduli must be a signing set of at
+// least two active drones whose product reaches PMin2, for a single drone
+// u_i = r_i and U_i would reveal its remainder to a search.
+func (crt *CRTSharing) SigningCommitments(moduli []*bigint.Int) ([]group.Element, error) {
+	if crt.Remainder == nil {
+		return nil, ErrNoSecret
+	}
+	index := make([]int, 0, len(moduli))
+	for _, m := range moduli {
+		j := crt.index(m)
+		if j < 0 {
+			return nil, ErrUnknownModulus
+		}
+		if crt.IsRevoked(m) {
+			return nil, ErrRevoked
+		}
+		index = append(index, j)
+	}
+	if ok, _ := crt.GroupParams.CanSign(moduli); !ok || len(moduli) < 2 {
+		return nil, ErrNotQualified
+	}
+	ctx, err := NewCRTContext(moduli)
+	if err != nil {
+		return nil, err
+	}
+	U := make([]group.Element, 0, len(moduli))
+	for i, j := range index {
+		u := ctx.share(i, crt.Remainder[j])
+		U = append(U, shareCommitment(crt.Group, u))
+		bigint.Clear(u)
+	}
+	return U, nil
+}
+
+// VerifyPartials checks the partial signatures of all the drones of B,
+// s[i], R[i] and U[i] belong to B[i]. It returns the indices of the drones
+// whose partial signatures are invalid together with ErrPartialSignature, so
+// they can be excluded before signing again.
+func VerifyPartials(h Hasher, m string, s []*bigint.Int, R []group.Element, U []group.Element, pub group.Element, B B) ([]int, error) {
+	return VerifyPartialsMessage(h, NewMessage([]byte(m)), s, R, U, pub, B)
+}
+
+// VerifyPartialsMessage is VerifyPartials with a message that may be
+// prehashed, the message is hashed once for all the drones
+func VerifyPartialsMessage(h Hasher, msg Message, s []*bigint.Int, R []group.Element, U []group.Element, pub group.Element, B B) ([]int, error) {
+	if err := msg.check(h); err != nil {
+		return nil, err
+	}
+	if len(s) != len(B) || len(R) != len(B) || len(U) != len(B) {
+		return nil, fmt.Errorf("%w: s = %d, R = %d, U = %d, B = %d", ErrPartialLength, len(s), len(R), len(U), len(B))
+	}
+	var failed []int
+	for i := range B {
+		if !verifyPartial(h, msg, s[i], R[i], U[i], pub, B, i) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) != 0 {
+		return failed, fmt.Errorf("%w: %d of %d", ErrPartialSignature, len(failed), len(B))
+	}
+	return nil, nil
+}
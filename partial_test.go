@@ -0,0 +1,77 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPartials(t *testing.T) {
+	once()
+	T := crt.ThresholdT2
+	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
+	m := "Hello World"
+
+	signs := make([]*bigint.Int, 0, T)
+	Rs := make([]group.Element, 0, T)
+	for i := 0; i < T; i++ {
+		signer := scheme.NewSigner(ei[i], di[i], crt.Remainder[i], crt.Pub, B[i])
+		s, R, err := signer.Sign(m, crt.Pub, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		Rs = append(Rs, R)
+	}
+	U, err := crt.SigningCommitments(moduli[:T])
+	assert.NoError(t, err)
+
+	failed, err := scheme.VerifyPartials(scheme.DefaultSuite, m, signs, Rs, U, crt.Pub, B)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+
+	// The commitments depend on the signing set
+	other, err := crt.SigningCommitments(moduli[1 : T+1])
+	assert.NoError(t, err)
+	assert.False(t, U[1].IsEqual(other[0]))
+	_, err = crt.SigningCommitments([]*bigint.Int{bigint.NewInt(7)})
+	assert.ErrorIs(t, err, scheme.ErrUnknownModulus)
+
+	// A single drone or a set below PMin2 gets no commitments
+	_, err = crt.SigningCommitments(moduli[:1])
+	assert.ErrorIs(t, err, scheme.ErrNotQualified)
+	_, err = crt.SigningCommitments(moduli[:2])
+	assert.ErrorIs(t, err, scheme.ErrNotQualified)
+
+	// s + q is equal modulo q but not reduced
+	q := new(bigint.Int).SetBytes(crt.Group.Order())
+	signs[1] = new(bigint.Int).Add(signs[1], q)
+	// A drone signing with a wrong remainder
+	signer := scheme.NewSigner(ei[3], di[3], crt.Remainder[4], crt.Pub, B[3])
+	signs[3], _, _ = signer.Sign(m, crt.Pub, B)
+	// A wrong commitment R
+	Rs[5] = crt.Pub
+
+	failed, err = scheme.VerifyPartials(scheme.DefaultSuite, m, signs, Rs, U, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrPartialSignature)
+	assert.Equal(t, []int{1, 3, 5}, failed)
+
+	_, err = scheme.VerifyPartials(scheme.DefaultSuite, m, signs[1:], Rs, U, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrPartialLength)
+}
+
+func TestCommitments(t *testing.T) {
+	once()
+	assert.Len(t, crt.Commitments, crt.N)
+
+	data, err := crt.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.CRTSharing)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, decoded.Validate())
+	assert.True(t, crt.Commitments[0].IsEqual(decoded.Commitments[0]))
+
+	decoded.Commitments[2] = crt.Commitments[3]
+	assert.ErrorIs(t, decoded.Validate(), scheme.ErrCommitmentMismatch)
+}
@@ -0,0 +1,92 @@
+package scheme
+
+import (
+	"github.com/52funny/scheme/bigint"
+)
+
+// CanSign reports whether the drones holding the moduli can produce a valid
+// signature together, that is whether the product of their moduli reaches
+// PMin2. The partial signatures aggregate once the product exceeds S, so PMin2
+// is PMin1 and any set that can recover the secret can sign. Duplicate,
+// revoked and unknown moduli do not count. The margin is the number of bits by
+// which the product exceeds the bound, or minus the number of bits it falls
+// short.
+func (gp *GroupParams) CanSign(moduli []*bigint.Int) (bool, int) {
+	bound := gp.signingBound()
+	defer bigint.Clear(bound)
+	return gp.qualified(moduli, bound)
+}
+
+// CanSign is GroupParams.CanSign with the exact bound S, which the product
+// must exceed
+func (crt *CRTSharing) CanSign(moduli []*bigint.Int) (bool, int) {
+	bound := crt.signingBound()
+	defer bigint.Clear(bound)
+	return crt.qualified(moduli, bound)
+}
+
+// signingBound returns PMin2 - 1, the bound the product of the signers must exceed
+func (gp *GroupParams) signingBound() *bigint.Int {
+	return new(bigint.Int).Sub(gp.PMin2, bigint.NewInt(1))
+}
+
+// signingBound returns S, or PMin2 - 1 once the secret is wiped
+func (crt *CRTSharing) signingBound() *bigint.Int {
+	if crt.Secret != nil {
+		return signBound(crt.Secret)
+	}
+	return crt.GroupParams.signingBound()
+}
+
+// CanRecover reports whether the drones holding the moduli can recover the
+// secret modulo p, that is whether the product of their moduli exceeds
+// RecoveryBound. Duplicate, revoked and unknown moduli do not count. The margin is
+// as in CanSign.
+func (gp *GroupParams) CanRecover(moduli []*bigint.Int) (bool, int) {
+	bound := gp.RecoveryBound()
+	defer bigint.Clear(bound)
+	return gp.qualified(moduli, bound)
+}
+
+// RecoveryBound returns Dealers * (L+1) * p, the secret is at most the bound.
+// The secret of a key generation is the sum of the N secrets of the dealings.
+func (gp *GroupParams) RecoveryBound() *bigint.Int {
+	L := boundL(gp.PMax)
+	defer bigint.Clear(L)
+	p := groupOrder(gp.Group)
+	defer bigint.Clear(p)
+	b := recoverBound(L, p)
+	if gp.Dealers > 1 {
+		b.Mul(b, bigint.NewInt(int64(gp.Dealers)))
+	}
+	return b
+}
+
+// qualified compares the product of the distinct active moduli with the bound
+func (gp *GroupParams) qualified(moduli []*bigint.Int, bound *bigint.Int) (bool, int) {
+	seen := make([]bool, len(gp.Moduli))
+	P := bigint.NewInt(1)
+	defer bigint.Clear(P)
+	for _, m := range moduli {
+		i := gp.index(m)
+		if i < 0 || seen[i] || (gp.Revoked != nil && gp.Revoked[i]) {
+			continue
+		}
+		seen[i] = true
+		P.Mul(P, gp.Moduli[i])
+	}
+	return P.Cmp(bound) == 1, marginBits(P, bound)
+}
+
+// marginBits returns floor(log2(x / y)) if x >= y and -ceil(log2(y / x)) otherwise
+func marginBits(x, y *bigint.Int) int {
+	q := new(bigint.Int)
+	defer bigint.Clear(q)
+	if x.Cmp(y) >= 0 {
+		return q.Quo(x, y).BitLen() - 1
+	}
+	// ceil(y / x) - 1 = floor((y - 1) / x)
+	q.Sub(y, bigint.NewInt(1))
+	q.Quo(q, x)
+	return -q.BitLen()
+}
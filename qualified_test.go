@@ -0,0 +1,65 @@
+package scheme_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanSign(t *testing.T) {
+	n := 12
+	mod := scheme.GenerateNumber([]int{256}, n)
+	crt, err := scheme.TryNewCRTSharing(n, 4, mod)
+	assert.NoError(t, err)
+	T1, T2 := crt.ThresholdT1, crt.ThresholdT2
+	assert.Equal(t, T1, T2)
+
+	ok, margin := crt.CanSign(mod[:T2])
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, margin, 0)
+
+	// With the secret the product only has to exceed S
+	P := bigint.NewInt(1)
+	for _, m := range mod[:T2-1] {
+		P.Mul(P, m)
+	}
+	ok, _ = crt.CanSign(mod[:T2-1])
+	assert.Equal(t, P.Cmp(crt.Secret) == 1, ok)
+	ok, _ = crt.CanSign([]*bigint.Int{mod[0]})
+	assert.Equal(t, mod[0].Cmp(crt.Secret) == 1, ok)
+
+	// Any T2 moduli have a product of at least PMin2
+	ok, _ = crt.CanSign(mod[n-T2:])
+	assert.True(t, ok)
+
+	// Without the secret PMin2 is the bound
+	public := *crt
+	public.Secret = nil
+	ok, _ = public.CanSign(mod[:T2])
+	assert.True(t, ok)
+	ok, _ = public.CanSign(mod[:T2-1])
+	assert.False(t, ok)
+
+	// Duplicates and unknown moduli do not count
+	dup := append(slices.Clone(mod[:T2-1]), mod[0], bigint.NewInt(7), nil)
+	ok, _ = public.CanSign(dup)
+	assert.False(t, ok)
+
+	ok, margin = crt.CanRecover(mod[:T1])
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, margin, 0)
+	ok, _ = crt.CanRecover(mod[:T1-1])
+	assert.False(t, ok)
+
+	// Revoked moduli do not count
+	assert.NoError(t, crt.Revoke(mod[0]))
+	ok, margin = crt.CanRecover(mod[:T1])
+	ok1, margin1 := crt.CanRecover(mod[1:T1])
+	assert.Equal(t, ok1, ok)
+	assert.Equal(t, margin1, margin)
+	ok, _ = crt.CanRecover(mod[1 : T1+1])
+	assert.True(t, ok)
+}
@@ -0,0 +1,135 @@
+package scheme
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrNoSecret   = errors.New("scheme: the sharing does not hold the secret")
+	ErrShareProof = errors.New("scheme: invalid proof of the share")
+)
+
+// Domain separation tag of the proofs that a drone holds its share
+const dstShare = dstPrefix + "share"
+
+// Refresh re-randomizes the shares without changing the public key. The secret
+// S = p0 + alpha * p is replaced by S' = p0 + alpha' * p for a fresh alpha' in
+// [0, L], so S mod p and Pub stay the same while the old remainders can not be
+// combined with the new ones. PMin2 is recomputed for the new secret.
+// It returns the offset (S' - S) mod m_i of every drone in the order of Moduli,
+// which must be sent to the drone over a private channel and applied with
+// RefreshShare.
+func (crt *CRTSharing) Refresh() ([]*bigint.Int, error) {
+	if crt.Secret == nil {
+		return nil, ErrNoSecret
+	}
+	if err := crt.Validate(); err != nil {
+		return nil, err
+	}
+
+	pMax := productMax(crt.Moduli, crt.Thresholdt)
+	defer bigint.Clear(pMax)
+	L := boundL(pMax)
+	defer bigint.Clear(L)
+	p := groupOrder(crt.Group)
+	defer bigint.Clear(p)
+
+	// S' = p0 + alpha' * p
+	S := randInt(L)
+	S.Mul(S, p)
+	p0 := new(bigint.Int).Mod(crt.Secret, p)
+	S.Add(S, p0)
+	bigint.Clear(p0)
+
+	// The signing threshold follows the new secret
+	right := signBound(S)
+	defer bigint.Clear(right)
+	T2, pMin2 := prefixThreshold(crt.ActiveModuli(), crt.ThresholdT1, crt.PMin1, right)
+	if pMin2.Cmp(right) != 1 {
+		bigint.Clear(S)
+		bigint.Clear(pMin2)
+		return nil, ErrPMin2Boundary
+	}
+	crt.ThresholdT2, crt.PMin2 = T2, pMin2
+
+	// delta = S' - S
+	delta := new(bigint.Int).Sub(S, crt.Secret)
+	defer bigint.Clear(delta)
+	offsets := make([]*bigint.Int, 0, crt.N)
+	for i, m := range crt.Moduli {
+		offsets = append(offsets, new(bigint.Int).Mod(delta, m))
+		crt.Remainder[i] = RefreshShare(m, crt.Remainder[i], offsets[i])
+	}
+	bigint.Clear(crt.Secret)
+	crt.Secret = S
+	crt.Commitments = shareCommitments(crt.Group, crt.Remainder)
+	return offsets, nil
+}
+
+// RefreshShare returns the remainder (r + offset) mod m after a refresh
+func RefreshShare(modulus, remainder, offset *bigint.Int) *bigint.Int {
+	r := new(bigint.Int).Add(remainder, offset)
+	return r.Mod(r, modulus)
+}
+
+// ProveShare proves that the drone of the modulus holds the remainder without
+// revealing it, so the dealer releases the refresh offset to the drone only.
+// The proof is a Schnorr signature of the context under r mod q, the context
+// names the request and its epoch so that the proof can not be replayed.
+func ProveShare(g Group, modulus, remainder *bigint.Int, context []byte) *Signature {
+	h := DefaultSuite
+	x := IntToScalar(g, remainder)
+	defer x.SetUint64(0)
+	Y := g.NewElement().MulGen(x)
+	k := g.RandomNonZeroScalar(rand.Reader)
+	defer k.SetUint64(0)
+	R := g.NewElement().MulGen(k)
+	s := g.NewScalar().Mul(shareChallenge(h, Y, R, modulus, context), x)
+	s.Add(s, k)
+	return &Signature{Suite: h.ID(), R: R, S: s}
+}
+
+// VerifyShareProof checks a proof of ProveShare against Y = r * G, the
+// commitment to the remainder the drone of the modulus is expected to hold
+func VerifyShareProof(Y group.Element, modulus *bigint.Int, context []byte, proof *Signature) error {
+	if Y == nil || modulus == nil || proof == nil || proof.R == nil || proof.S == nil {
+		return ErrShareProof
+	}
+	h, err := SuiteByID(proof.Suite)
+	if err != nil {
+		return err
+	}
+	g := groupOf(Y.Group())
+	if proof.Group() != g || groupOf(proof.S.Group()) != g {
+		return ErrShareProof
+	}
+	// s * G == R + c * Y
+	lhs := g.NewElement().MulGen(proof.S)
+	rhs := g.NewElement().Mul(Y, shareChallenge(h, Y, proof.R, modulus, context))
+	rhs.Add(rhs, proof.R)
+	if !lhs.IsEqual(rhs) {
+		return ErrShareProof
+	}
+	return nil
+}
+
+// shareChallenge hashes Y || R || modulus || context under dstShare
+func shareChallenge(h Hasher, Y, R group.Element, modulus *bigint.Int, context []byte) group.Scalar {
+	return h.HashToScalar(groupOf(Y.Group()), dstShare, elementBytes(Y), elementBytes(R), appendInt(nil, modulus), context)
+}
+
+// randInt returns a uniform random integer in [0, max]
+func randInt(max *bigint.Int) *bigint.Int {
+	n := new(big.Int).SetBytes(max.Bytes())
+	n.Add(n, big.NewInt(1))
+	x, err := rand.Int(rand.Reader, n)
+	if err != nil {
+		panic(err)
+	}
+	return new(bigint.Int).SetBytes(x.Bytes())
+}
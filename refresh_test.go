@@ -0,0 +1,96 @@
+package scheme_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefresh(t *testing.T) {
+	n := 8
+	mod := scheme.GenerateNumber([]int{256}, n)
+	crt, err := scheme.TryNewCRTSharing(n, 4, mod)
+	assert.NoError(t, err)
+	pub := crt.Pub
+	oldRemainder := make([]*bigint.Int, 0, n)
+	for _, r := range crt.Remainder {
+		oldRemainder = append(oldRemainder, new(bigint.Int).Set(r))
+	}
+	oldSecret := new(bigint.Int).Set(crt.Secret)
+
+	offsets, err := crt.Refresh()
+	assert.NoError(t, err)
+	assert.Len(t, offsets, n)
+	assert.NoError(t, crt.Validate())
+	assert.True(t, pub.IsEqual(crt.Pub))
+	assert.NotEqual(t, 0, oldSecret.Cmp(crt.Secret))
+
+	// The drones update their remainders with the offsets
+	g := crt.Group
+	T := crt.ThresholdT2
+	Ei := make([]group.Element, 0, T)
+	Di := make([]group.Element, 0, T)
+	signers := make([]*scheme.Signer, 0, T)
+	for i := 0; i < T; i++ {
+		e, d := g.RandomScalar(rand.Reader), g.RandomScalar(rand.Reader)
+		Ei = append(Ei, g.NewElement().MulGen(e))
+		Di = append(Di, g.NewElement().MulGen(d))
+		signers = append(signers, scheme.NewSigner(e, d, new(bigint.Int).Set(oldRemainder[i]), crt.Pub, scheme.BItem{P: mod[i]}))
+	}
+	B := scheme.NewB(mod[:T], Ei, Di)
+	P := new(bigint.Int).SetInt64(1)
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
+	for i, signer := range signers {
+		signer.BItem = B[i]
+		signer.Refresh(offsets[i])
+		assert.Equal(t, 0, scheme.RefreshShare(mod[i], oldRemainder[i], offsets[i]).Cmp(crt.Remainder[i]))
+		s, r, err := signer.Sign("Hello World", crt.Pub, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		R = r
+		P.Mul(P, mod[i])
+	}
+	sig, err := scheme.AggregateSignature(scheme.DefaultSuite, scheme.NewMessage([]byte("Hello World")), signs, R, crt.Pub, P)
+	assert.NoError(t, err)
+	assert.True(t, sig.Verify(crt.Pub, "Hello World"))
+
+	// Old and new shares do not recover the secret together
+	T1 := crt.ThresholdT1
+	mixed := append([]*bigint.Int{oldRemainder[0]}, crt.Remainder[1:T1]...)
+	assert.NotEqual(t, 0, scheme.ReconstructSecret(mod[:T1], mixed).Cmp(crt.Secret))
+	assert.Equal(t, 0, scheme.ReconstructSecret(mod[:T1], crt.Remainder[:T1]).Cmp(crt.Secret))
+
+	// Only the dealer can refresh
+	crt.Secret = nil
+	_, err = crt.Refresh()
+	assert.ErrorIs(t, err, scheme.ErrNoSecret)
+}
+
+func TestProveShare(t *testing.T) {
+	once()
+	g := crt.Group
+	m, r := crt.Moduli[0], crt.Remainder[0]
+	Y := crt.Commitment(m)
+	ctx := []byte("refresh 1")
+
+	proof := scheme.ProveShare(g, m, r, ctx)
+	assert.NoError(t, scheme.VerifyShareProof(Y, m, ctx, proof))
+	data, err := proof.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.Signature)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, scheme.VerifyShareProof(Y, m, ctx, decoded))
+
+	// The proof is bound to the context, the modulus and the remainder
+	assert.ErrorIs(t, scheme.VerifyShareProof(Y, m, []byte("refresh 2"), proof), scheme.ErrShareProof)
+	assert.ErrorIs(t, scheme.VerifyShareProof(Y, crt.Moduli[1], ctx, proof), scheme.ErrShareProof)
+	assert.ErrorIs(t, scheme.VerifyShareProof(crt.Commitment(crt.Moduli[1]), m, ctx, proof), scheme.ErrShareProof)
+	wrong := scheme.ProveShare(g, m, crt.Remainder[1], ctx)
+	assert.ErrorIs(t, scheme.VerifyShareProof(Y, m, ctx, wrong), scheme.ErrShareProof)
+	assert.ErrorIs(t, scheme.VerifyShareProof(Y, m, ctx, nil), scheme.ErrShareProof)
+}
@@ -0,0 +1,232 @@
+package scheme
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrUnknownModulus = errors.New("scheme: modulus is not part of the sharing")
+	ErrRevoked        = errors.New("scheme: modulus is revoked")
+	ErrRevocationList = errors.New("scheme: invalid revocation list")
+)
+
+// Domain separation tag of the revocation lists signed by the TA
+const dstRevocation = dstPrefix + "revocation"
+
+// Revoke marks the share of modulus as revoked. Revoked moduli no longer count
+// towards ThresholdT1 and ThresholdT2, which are recomputed over the active
+// moduli. A revoked share may be in the hands of an adversary, so PMax and L
+// still include it, Refresh afterwards makes it useless. On error the sharing
+// is left unchanged.
+func (crt *CRTSharing) Revoke(modulus *bigint.Int) error {
+	if crt.Secret == nil {
+		return ErrNoSecret
+	}
+	right := signBound(crt.Secret)
+	defer bigint.Clear(right)
+	return crt.GroupParams.revoke(modulus, right)
+}
+
+// Revoke is CRTSharing.Revoke without the secret. The signers must then
+// exceed RecoveryBound instead of S, so PMin2 is PMin1.
+func (gp *GroupParams) Revoke(modulus *bigint.Int) error {
+	return gp.revoke(modulus, nil)
+}
+
+// revoke recomputes the thresholds over the active moduli, the signers must
+// exceed right, or RecoveryBound if right is nil
+func (gp *GroupParams) revoke(modulus *bigint.Int, right *bigint.Int) error {
+	i := gp.index(modulus)
+	if i < 0 {
+		return ErrUnknownModulus
+	}
+	if gp.IsRevoked(modulus) {
+		return ErrRevoked
+	}
+	revoked := slices.Clone(gp.Revoked)
+	if revoked == nil {
+		revoked = make([]bool, gp.N)
+	}
+	revoked[i] = true
+	active := activeModuli(gp.Moduli, revoked)
+
+	left := gp.RecoveryBound()
+	defer bigint.Clear(left)
+	if right == nil {
+		right = left
+	}
+	T1, pMin1 := prefixThreshold(active, 0, bigint.NewInt(1), left)
+	T2, pMin2 := prefixThreshold(active, T1, pMin1, right)
+	var err error
+	switch {
+	case pMin1.Cmp(left) != 1:
+		err = ErrPMin1Boundary
+	case pMin2.Cmp(right) != 1:
+		err = ErrPMin2Boundary
+	}
+	if err != nil {
+		bigint.Clear(pMin1)
+		bigint.Clear(pMin2)
+		return fmt.Errorf("%w: %d active moduli left", err, len(active))
+	}
+
+	gp.Revoked = revoked
+	gp.ThresholdT1, gp.ThresholdT2 = T1, T2
+	gp.PMin1, gp.PMin2 = pMin1, pMin2
+	return nil
+}
+
+// IsRevoked reports whether the modulus is revoked
+func (gp *GroupParams) IsRevoked(modulus *bigint.Int) bool {
+	i := gp.index(modulus)
+	return i >= 0 && gp.Revoked != nil && gp.Revoked[i]
+}
+
+// index returns the index of the modulus, or -1 if it is not part of the sharing
+func (gp *GroupParams) index(modulus *bigint.Int) int {
+	if modulus == nil {
+		return -1
+	}
+	return slices.IndexFunc(gp.Moduli, func(m *bigint.Int) bool { return m.Cmp(modulus) == 0 })
+}
+
+// RevocationList is the list of revoked moduli, signed with the revocation
+// key of the TA so that aggregators can check it came from the TA. The version
+// grows with every new list so that an older list can not be replayed.
+// Revocations are permanent, so a newer list always contains the older ones.
+type RevocationList struct {
+	Version   uint64        // Version of the list, increased with every new list
+	Moduli    []*bigint.Int // The revoked moduli in ascending order
+	Signature *Signature    // Schnorr signature of the TA
+}
+
+// RevocationKey is the key the TA signs the revocation lists with. It is
+// separate from the group key, so lists are signed without the dealer secret,
+// after Wipe and when the drones generated the group key.
+type RevocationKey struct {
+	Secret group.Scalar  // Signing key
+	Pub    group.Element // Public key the aggregators verify the lists with
+}
+
+// NewRevocationKey returns a random revocation key in the group g
+func NewRevocationKey(g Group) *RevocationKey {
+	x := g.RandomNonZeroScalar(rand.Reader)
+	return &RevocationKey{Secret: x, Pub: g.NewElement().MulGen(x)}
+}
+
+// RevocationList returns the revoked moduli as the list of the version,
+// signed with the revocation key
+func (gp *GroupParams) RevocationList(key *RevocationKey, version uint64) *RevocationList {
+	rl := &RevocationList{Version: version, Moduli: make([]*bigint.Int, 0)}
+	for i, m := range gp.Moduli {
+		if gp.Revoked != nil && gp.Revoked[i] {
+			rl.Moduli = append(rl.Moduli, m)
+		}
+	}
+	key.sign(rl)
+	return rl
+}
+
+// sign signs the list, s = k + c * x
+func (key *RevocationKey) sign(rl *RevocationList) {
+	g := groupOf(key.Pub.Group())
+	h := DefaultSuite
+	k := g.RandomNonZeroScalar(rand.Reader)
+	R := g.NewElement().MulGen(k)
+	s := g.NewScalar().Mul(rl.challenge(h, R, key.Pub), key.Secret)
+	s.Add(s, k)
+	k.SetUint64(0)
+	rl.Signature = &Signature{Suite: h.ID(), R: R, S: s}
+}
+
+// challenge hashes pub || R || version || count || moduli under
+// dstRevocation, apart from the challenges of the threshold signatures
+func (rl *RevocationList) challenge(h Hasher, R, pub group.Element) group.Scalar {
+	return h.HashToScalar(groupOf(pub.Group()), dstRevocation, elementBytes(pub), elementBytes(R), rl.message())
+}
+
+// message returns version || count || moduli
+func (rl *RevocationList) message() []byte {
+	buf := binary.AppendUvarint(nil, rl.Version)
+	buf = binary.AppendUvarint(buf, uint64(len(rl.Moduli)))
+	for _, m := range rl.Moduli {
+		buf = appendInt(buf, m)
+	}
+	return buf
+}
+
+// Verify checks the signature of the revocation key pub, that the moduli are
+// sorted and that the list is not older than version, the version of the last
+// list that was accepted
+func (rl *RevocationList) Verify(pub group.Element, version uint64) bool {
+	if rl.Version < version || pub == nil {
+		return false
+	}
+	sig := rl.Signature
+	if sig == nil || sig.R == nil || sig.S == nil || slices.ContainsFunc(rl.Moduli, func(m *bigint.Int) bool { return m == nil }) ||
+		!slices.IsSortedFunc(rl.Moduli, func(x, y *bigint.Int) int { return x.Cmp(y) }) {
+		return false
+	}
+	h, err := SuiteByID(sig.Suite)
+	if err != nil {
+		return false
+	}
+	g := groupOf(pub.Group())
+	if sig.Group() != g || groupOf(sig.S.Group()) != g {
+		return false
+	}
+	// s * G == R + c * pub
+	lhs := g.NewElement().MulGen(sig.S)
+	rhs := g.NewElement().Mul(pub, rl.challenge(h, sig.R, pub))
+	rhs.Add(rhs, sig.R)
+	return lhs.IsEqual(rhs)
+}
+
+// Contains reports whether the modulus is revoked
+func (rl *RevocationList) Contains(modulus *bigint.Int) bool {
+	_, found := slices.BinarySearchFunc(rl.Moduli, modulus, func(x, y *bigint.Int) int { return x.Cmp(y) })
+	return modulus != nil && found
+}
+
+// MarshalBinary encodes the list as version || count || moduli || signature
+func (rl *RevocationList) MarshalBinary() ([]byte, error) {
+	if rl.Signature == nil {
+		return nil, ErrRevocationList
+	}
+	sig, err := rl.Signature.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return append(rl.message(), sig...), nil
+}
+
+// UnmarshalBinary decodes a list produced by MarshalBinary,
+// call Verify to check the signature
+func (rl *RevocationList) UnmarshalBinary(data []byte) error {
+	r := &reader{buf: data}
+	version := r.uvarint()
+	n := r.int()
+	if r.err == nil && n > len(r.buf) {
+		r.fail()
+	}
+	moduli := make([]*bigint.Int, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		moduli = append(moduli, r.bigInt())
+	}
+	if r.err != nil {
+		return r.err
+	}
+	sig := new(Signature)
+	if err := sig.Unmarshal(r.buf); err != nil {
+		return err
+	}
+	rl.Version, rl.Moduli, rl.Signature = version, moduli, sig
+	return nil
+}
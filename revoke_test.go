@@ -0,0 +1,130 @@
+package scheme_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevoke(t *testing.T) {
+	n := 8
+	mod := scheme.GenerateNumber([]int{256}, n)
+	crt, err := scheme.TryNewCRTSharing(n, 4, mod)
+	assert.NoError(t, err)
+	pub := crt.Pub
+
+	// Revoking the smallest modulus moves the thresholds to the next ones
+	assert.NoError(t, crt.Revoke(mod[0]))
+	assert.True(t, crt.IsRevoked(mod[0]))
+	assert.False(t, crt.IsRevoked(mod[1]))
+	assert.Len(t, crt.ActiveModuli(), n-1)
+	assert.NoError(t, crt.Validate())
+	assert.True(t, pub.IsEqual(crt.Pub))
+	active := crt.ActiveModuli()
+	T1 := crt.ThresholdT1
+	assert.Equal(t, 0, crt.PMin1.Cmp(product(active[:T1])))
+
+	assert.ErrorIs(t, crt.Revoke(mod[0]), scheme.ErrRevoked)
+	assert.ErrorIs(t, crt.Revoke(bigint.NewInt(7)), scheme.ErrUnknownModulus)
+
+	// The revoked moduli survive both encodings
+	data, err := crt.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.CRTSharing)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, decoded.Validate())
+	assert.True(t, decoded.IsRevoked(mod[0]))
+	js, err := json.Marshal(crt)
+	assert.NoError(t, err)
+	decoded = new(scheme.CRTSharing)
+	assert.NoError(t, json.Unmarshal(js, decoded))
+	assert.True(t, decoded.IsRevoked(mod[0]))
+
+	// Revoking until the active moduli can not sign leaves the sharing unchanged
+	var last error
+	for _, m := range mod[1:] {
+		if last = crt.Revoke(m); last != nil {
+			assert.False(t, crt.IsRevoked(m))
+			break
+		}
+	}
+	assert.Error(t, last)
+	assert.NoError(t, crt.Validate())
+}
+
+func TestGroupParamsRevoke(t *testing.T) {
+	n := 8
+	mod := scheme.GenerateNumber([]int{256}, n)
+	crt, err := scheme.TryNewCRTSharing(n, 4, mod)
+	assert.NoError(t, err)
+	params := crt.Params()
+	crt.Wipe()
+
+	// Without the secret the signers must exceed the recovery bound
+	assert.NoError(t, params.Revoke(mod[0]))
+	assert.True(t, params.IsRevoked(mod[0]))
+	assert.NoError(t, params.Validate())
+	assert.Equal(t, params.ThresholdT1, params.ThresholdT2)
+	assert.Equal(t, 0, params.PMin1.Cmp(params.PMin2))
+	active := params.ActiveModuli()
+	assert.Equal(t, 0, params.PMin1.Cmp(product(active[:params.ThresholdT1])))
+	ok, _ := params.CanRecover(active[:params.ThresholdT1])
+	assert.True(t, ok)
+
+	assert.ErrorIs(t, params.Revoke(mod[0]), scheme.ErrRevoked)
+	assert.ErrorIs(t, params.Revoke(bigint.NewInt(7)), scheme.ErrUnknownModulus)
+}
+
+func TestRevocationList(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 12)
+	crt, err := scheme.TryNewCRTSharing(12, 4, mod)
+	assert.NoError(t, err)
+	assert.NoError(t, crt.Revoke(mod[5]))
+	assert.NoError(t, crt.Revoke(mod[2]))
+
+	// The TA signs without the dealer secret
+	key := scheme.NewRevocationKey(crt.Group)
+	params := crt.Params()
+	crt.Wipe()
+	rl := params.RevocationList(key, 2)
+	assert.True(t, rl.Verify(key.Pub, 0))
+	assert.True(t, rl.Verify(key.Pub, 2))
+	assert.True(t, rl.Contains(mod[2]))
+	assert.True(t, rl.Contains(mod[5]))
+	assert.False(t, rl.Contains(mod[3]))
+
+	data, err := rl.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.RevocationList)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, uint64(2), decoded.Version)
+	assert.True(t, decoded.Verify(key.Pub, 2))
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrSignatureLength)
+
+	// An older list is not accepted after a newer one
+	assert.False(t, rl.Verify(key.Pub, 3))
+	decoded.Version = 3
+	assert.False(t, decoded.Verify(key.Pub, 3))
+
+	// Dropping a revoked modulus breaks the signature
+	decoded.Version = 2
+	decoded.Moduli = decoded.Moduli[1:]
+	assert.False(t, decoded.Verify(key.Pub, 0))
+	assert.False(t, rl.Verify(params.Pub, 0))
+	assert.False(t, rl.Verify(scheme.NewRevocationKey(crt.Group).Pub, 0))
+
+	// The signature is not that of a message
+	m, _ := rl.MarshalBinary()
+	assert.False(t, rl.Signature.VerifyBytes(key.Pub, m))
+}
+
+func product(moduli []*bigint.Int) *bigint.Int {
+	p := bigint.NewInt(1)
+	for _, m := range moduli {
+		p.Mul(p, m)
+	}
+	return p
+}
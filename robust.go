@@ -0,0 +1,122 @@
+package scheme
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+)
+
+var (
+	ErrRedundancy    = errors.New("scheme: not enough redundant shares to correct the errors")
+	ErrUncorrectable = errors.New("scheme: more faulty remainders than can be corrected")
+)
+
+// ReconstructSecretRobust reconstructs a secret of at most bound from
+// redundant shares and corrects up to maxErrors faulty remainders. It returns
+// the secret together with the indices of the faulty remainders in ascending
+// order.
+//
+// The moduli form a redundant residue number system: if the product of any
+// n - 2 * maxErrors of them exceeds bound, two secrets of at most bound
+// differ in more than 2 * maxErrors remainders, so the only subset of at
+// least n - maxErrors shares that reconstructs to at most bound gives the
+// secret. The subsets are searched by the number of left out shares, which
+// takes up to C(n, maxErrors) reconstructions. Remainders outside of
+// [0, modulus) are faulty by themselves and are always left out.
+func ReconstructSecretRobust(moduli []*bigint.Int, remainder []*bigint.Int, bound *bigint.Int, maxErrors int) (*bigint.Int, []int, error) {
+	n := len(moduli)
+	if len(remainder) != n {
+		return nil, nil, fmt.Errorf("%w: moduli = %d, remainder = %d", ErrLengthMismatch, n, len(remainder))
+	}
+	if maxErrors < 0 || n-2*maxErrors < 1 {
+		return nil, nil, fmt.Errorf("%w: %d shares, %d errors", ErrRedundancy, n, maxErrors)
+	}
+	if _, err := NewCRTContext(moduli); err != nil {
+		return nil, nil, err
+	}
+
+	// The n - 2 * maxErrors smallest moduli must exceed the bound
+	sorted := slices.SortedFunc(slices.Values(moduli), func(x, y *bigint.Int) int { return x.Cmp(y) })
+	P := product(sorted[:n-2*maxErrors])
+	defer bigint.Clear(P)
+	if P.Cmp(bound) != 1 {
+		return nil, nil, fmt.Errorf("%w: %d shares, %d errors", ErrRedundancy, n, maxErrors)
+	}
+
+	var erased []int
+	for i, r := range remainder {
+		if r == nil || r.Sign() < 0 || r.Cmp(moduli[i]) >= 0 {
+			erased = append(erased, i)
+		}
+	}
+	if len(erased) > maxErrors {
+		return nil, nil, fmt.Errorf("%w: %d remainders out of range", ErrUncorrectable, len(erased))
+	}
+
+	for k := len(erased); k <= maxErrors; k++ {
+		var found *bigint.Int
+		var faulty []int
+		combinations(n, k, func(left []int) bool {
+			for _, i := range erased {
+				if _, ok := slices.BinarySearch(left, i); !ok {
+					return true
+				}
+			}
+			x := reconstructWithout(moduli, remainder, left)
+			if x.Cmp(bound) <= 0 {
+				found, faulty = x, slices.Clone(left)
+				return false
+			}
+			bigint.Clear(x)
+			return true
+		})
+		if found != nil {
+			return found, faulty, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: up to %d", ErrUncorrectable, maxErrors)
+}
+
+// reconstructWithout reconstructs from all the shares except those of the indices in left
+func reconstructWithout(moduli []*bigint.Int, remainder []*bigint.Int, left []int) *bigint.Int {
+	m := make([]*bigint.Int, 0, len(moduli)-len(left))
+	r := make([]*bigint.Int, 0, len(moduli)-len(left))
+	for i := range moduli {
+		if _, ok := slices.BinarySearch(left, i); !ok {
+			m = append(m, moduli[i])
+			r = append(r, remainder[i])
+		}
+	}
+	// The moduli were checked to be pairwise coprime
+	ctx, _ := NewCRTContext(m)
+	x, _ := ctx.Reconstruct(r)
+	return x
+}
+
+// combinations calls yield with every k element subset of [0, n) in
+// lexicographic order until it returns false
+func combinations(n, k int, yield func([]int) bool) {
+	c := make([]int, k)
+	for i := range c {
+		c[i] = i
+	}
+	for {
+		if !yield(c) {
+			return
+		}
+		// Advance the rightmost index that can still move
+		i := k - 1
+		for i >= 0 && c[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		c[i]++
+		for j := i + 1; j < k; j++ {
+			c[j] = c[j-1] + 1
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package scheme_test
+
+import (
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconstructSecretRobust(t *testing.T) {
+	once()
+	bound := crt.RecoveryBound()
+	n := crt.ThresholdT1 + 4
+	mod := moduli[:n]
+	shares := func() []*bigint.Int {
+		r := make([]*bigint.Int, 0, n)
+		for _, x := range crt.Remainder[:n] {
+			r = append(r, new(bigint.Int).Set(x))
+		}
+		return r
+	}
+
+	// Correct shares need no correction
+	S, faulty, err := scheme.ReconstructSecretRobust(mod, shares(), bound, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, S.Cmp(crt.Secret))
+	assert.Empty(t, faulty)
+
+	// Two corrupted remainders are found and left out
+	remainder := shares()
+	remainder[7].Add(remainder[7], bigint.NewInt(1))
+	remainder[7].Mod(remainder[7], mod[7])
+	remainder[3].SetInt64(0)
+	assert.NotEqual(t, 0, scheme.ReconstructSecret(mod, remainder).Cmp(crt.Secret))
+	S, faulty, err = scheme.ReconstructSecretRobust(mod, remainder, bound, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, S.Cmp(crt.Secret))
+	assert.Equal(t, []int{3, 7}, faulty)
+
+	// A remainder out of range is faulty by itself
+	remainder = shares()
+	remainder[0].Add(remainder[0], mod[0])
+	S, faulty, err = scheme.ReconstructSecretRobust(mod, remainder, bound, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, S.Cmp(crt.Secret))
+	assert.Equal(t, []int{0}, faulty)
+
+	// Three errors are too many to correct
+	remainder = shares()
+	for _, i := range []int{1, 5, 9} {
+		remainder[i].SetInt64(1)
+	}
+	_, _, err = scheme.ReconstructSecretRobust(mod, remainder, bound, 2)
+	assert.ErrorIs(t, err, scheme.ErrUncorrectable)
+
+	// Without redundancy nothing can be corrected
+	_, _, err = scheme.ReconstructSecretRobust(mod, shares(), bound, 3)
+	assert.ErrorIs(t, err, scheme.ErrRedundancy)
+	_, _, err = scheme.ReconstructSecretRobust(mod, shares()[1:], bound, 1)
+	assert.ErrorIs(t, err, scheme.ErrLengthMismatch)
+}
@@ -0,0 +1,118 @@
+package scheme
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+)
+
+var (
+	ErrNotQualified = errors.New("scheme: the candidates can not form a qualified set")
+	ErrStrategy     = errors.New("scheme: unknown selection strategy")
+)
+
+// Strategy decides which drones SelectSigners prefers
+type Strategy int
+
+const (
+	FewestSigners Strategy = iota // As few drones as possible, the largest moduli first
+	LeastCost                     // The lowest total cost, the lowest cost per modulus bit first
+	HighWeight                    // The highest weights first, the cheaper drones among equal weights
+)
+
+// Candidate is a drone that is available for signing
+type Candidate struct {
+	Modulus *bigint.Int // Modulus of the drone
+	Cost    float64     // Cost hint of the drone, such as its signing time, 0 if unknown
+}
+
+// SelectSigners returns a minimal subset of the candidates whose moduli
+// multiply to at least bound, sorted by modulus so that it can be used as B.
+// The candidates are taken in the order of the strategy until the bound is
+// reached, then every candidate that is not needed is dropped again, the most
+// expensive first. Duplicate moduli are taken once at the lower cost.
+func SelectSigners(candidates []Candidate, bound *bigint.Int, s Strategy) ([]Candidate, error) {
+	var order func(x, y Candidate) int
+	switch s {
+	case FewestSigners:
+		order = func(x, y Candidate) int { return y.Modulus.Cmp(x.Modulus) }
+	case LeastCost:
+		order = func(x, y Candidate) int {
+			return cmp.Or(cmp.Compare(x.Cost/float64(x.Modulus.BitLen()), y.Cost/float64(y.Modulus.BitLen())),
+				y.Modulus.Cmp(x.Modulus))
+		}
+	case HighWeight:
+		order = func(x, y Candidate) int {
+			return cmp.Or(cmp.Compare(y.Modulus.BitLen(), x.Modulus.BitLen()), cmp.Compare(x.Cost, y.Cost),
+				y.Modulus.Cmp(x.Modulus))
+		}
+	default:
+		return nil, ErrStrategy
+	}
+	c := dedupCandidates(candidates)
+	slices.SortStableFunc(c, order)
+
+	P := bigint.NewInt(1)
+	defer bigint.Clear(P)
+	n := 0
+	for n < len(c) && P.Cmp(bound) < 0 {
+		P.Mul(P, c[n].Modulus)
+		n++
+	}
+	if P.Cmp(bound) < 0 {
+		return nil, ErrNotQualified
+	}
+	selected := c[:n]
+
+	// Drop the candidates that are not needed, the most expensive first
+	slices.SortStableFunc(selected, func(x, y Candidate) int {
+		return cmp.Or(cmp.Compare(y.Cost, x.Cost), x.Modulus.Cmp(y.Modulus))
+	})
+	rest := new(bigint.Int)
+	defer bigint.Clear(rest)
+	selected = slices.DeleteFunc(selected, func(x Candidate) bool {
+		if rest.Quo(P, x.Modulus).Cmp(bound) < 0 {
+			return false
+		}
+		P.Set(rest)
+		return true
+	})
+	slices.SortFunc(selected, func(x, y Candidate) int { return x.Modulus.Cmp(y.Modulus) })
+	return selected, nil
+}
+
+// SelectSigners returns a minimal subset of the candidates that CanSign,
+// revoked and unknown moduli are never selected
+func (gp *GroupParams) SelectSigners(candidates []Candidate, s Strategy) ([]Candidate, error) {
+	bound := gp.signingBound()
+	defer bigint.Clear(bound)
+	return gp.selectSigners(candidates, bound, s)
+}
+
+// SelectSigners is GroupParams.SelectSigners with the bound of CRTSharing.CanSign
+func (crt *CRTSharing) SelectSigners(candidates []Candidate, s Strategy) ([]Candidate, error) {
+	bound := crt.signingBound()
+	defer bigint.Clear(bound)
+	return crt.selectSigners(candidates, bound, s)
+}
+
+// selectSigners selects among the active candidates a set whose product exceeds the bound
+func (gp *GroupParams) selectSigners(candidates []Candidate, bound *bigint.Int, s Strategy) ([]Candidate, error) {
+	active := slices.DeleteFunc(slices.Clone(candidates), func(x Candidate) bool {
+		i := gp.index(x.Modulus)
+		return i < 0 || (gp.Revoked != nil && gp.Revoked[i])
+	})
+	return SelectSigners(active, bound.Add(bound, bigint.NewInt(1)), s)
+}
+
+// dedupCandidates returns the candidates with distinct non-nil moduli,
+// keeping the lowest cost of every modulus
+func dedupCandidates(candidates []Candidate) []Candidate {
+	c := slices.DeleteFunc(slices.Clone(candidates), func(x Candidate) bool { return x.Modulus == nil })
+	slices.SortStableFunc(c, func(x, y Candidate) int {
+		return cmp.Or(x.Modulus.Cmp(y.Modulus), cmp.Compare(x.Cost, y.Cost))
+	})
+	return slices.CompactFunc(c, func(x, y Candidate) bool { return x.Modulus.Cmp(y.Modulus) == 0 })
+}
@@ -0,0 +1,78 @@
+package scheme_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectSigners(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256, 512}, 16)
+	crt, err := scheme.TryNewCRTSharing(16, 4, mod)
+	assert.NoError(t, err)
+
+	candidates := make([]scheme.Candidate, 0, len(mod))
+	for i, m := range mod {
+		// The large moduli are the expensive ones
+		candidates = append(candidates, scheme.Candidate{Modulus: m, Cost: float64(i * i)})
+	}
+	counts := make(map[scheme.Strategy]int)
+	costs := make(map[scheme.Strategy]float64)
+	for _, s := range []scheme.Strategy{scheme.FewestSigners, scheme.LeastCost, scheme.HighWeight} {
+		selected, err := crt.SelectSigners(candidates, s)
+		assert.NoError(t, err)
+		moduli := make([]*bigint.Int, 0, len(selected))
+		for _, c := range selected {
+			moduli = append(moduli, c.Modulus)
+			costs[s] += c.Cost
+		}
+		counts[s] = len(selected)
+
+		// The selection can sign, sorted and without a redundant drone
+		ok, _ := crt.CanSign(moduli)
+		assert.True(t, ok)
+		assert.True(t, slices.IsSortedFunc(moduli, func(x, y *bigint.Int) int { return x.Cmp(y) }))
+		for i := range moduli {
+			ok, _ := crt.CanSign(append(moduli[:i:i], moduli[i+1:]...))
+			assert.False(t, ok)
+		}
+	}
+	assert.LessOrEqual(t, counts[scheme.FewestSigners], counts[scheme.LeastCost])
+	assert.LessOrEqual(t, counts[scheme.FewestSigners], counts[scheme.HighWeight])
+	assert.LessOrEqual(t, costs[scheme.LeastCost], costs[scheme.FewestSigners])
+
+	// Revoked moduli are never selected
+	assert.NoError(t, crt.Revoke(mod[len(mod)-1]))
+	selected, err := crt.SelectSigners(candidates, scheme.FewestSigners)
+	assert.NoError(t, err)
+	for _, c := range selected {
+		assert.NotEqual(t, 0, c.Modulus.Cmp(mod[len(mod)-1]))
+	}
+
+	_, err = crt.SelectSigners(candidates[:2], scheme.FewestSigners)
+	assert.ErrorIs(t, err, scheme.ErrNotQualified)
+	_, err = crt.SelectSigners(candidates, scheme.Strategy(-1))
+	assert.ErrorIs(t, err, scheme.ErrStrategy)
+}
+
+func TestSelectSignersBound(t *testing.T) {
+	c := []scheme.Candidate{
+		{Modulus: bigint.NewInt(7), Cost: 1},
+		{Modulus: bigint.NewInt(5), Cost: 1},
+		{Modulus: bigint.NewInt(3), Cost: 1},
+		{Modulus: bigint.NewInt(3), Cost: 0},
+		{Modulus: bigint.NewInt(2), Cost: 1},
+	}
+	selected, err := scheme.SelectSigners(c, bigint.NewInt(35), scheme.FewestSigners)
+	assert.NoError(t, err)
+	assert.Equal(t, []scheme.Candidate{c[1], c[0]}, selected)
+
+	// 2 * 3 * 5 is cheaper than 7 * 5 when 7 is expensive
+	c[0].Cost = 100
+	selected, err = scheme.SelectSigners(c, bigint.NewInt(30), scheme.LeastCost)
+	assert.NoError(t, err)
+	assert.Equal(t, []scheme.Candidate{c[4], c[3], c[1]}, selected)
+}
@@ -0,0 +1,61 @@
+package scheme
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// SessionContext identifies the signing session of a message. Bound to the
+// message with Message.WithContext it is hashed into rho and the challenge,
+// so a partial signature can not be replayed in another session and the
+// signature commits to the session it was produced in.
+type SessionContext struct {
+	SessionID    []byte // ID of the signing session
+	GroupID      []byte // ID of the signing group, such as its public key
+	Epoch        uint64 // Refresh epoch of the shares
+	AggregatorID []byte // ID of the aggregator running the session
+}
+
+// encode returns SessionID || GroupID || epoch || AggregatorID, where the IDs
+// are prefixed with their uvarint length and the epoch is a uvarint
+func (sc *SessionContext) encode() []byte {
+	buf := make([]byte, 0, 3*binary.MaxVarintLen64+len(sc.SessionID)+len(sc.GroupID)+len(sc.AggregatorID))
+	buf = binary.AppendUvarint(buf, uint64(len(sc.SessionID)))
+	buf = append(buf, sc.SessionID...)
+	buf = binary.AppendUvarint(buf, uint64(len(sc.GroupID)))
+	buf = append(buf, sc.GroupID...)
+	buf = binary.AppendUvarint(buf, sc.Epoch)
+	buf = binary.AppendUvarint(buf, uint64(len(sc.AggregatorID)))
+	return append(buf, sc.AggregatorID...)
+}
+
+// MarshalBinary encodes the context as it is hashed
+func (sc *SessionContext) MarshalBinary() ([]byte, error) {
+	return sc.encode(), nil
+}
+
+// UnmarshalBinary decodes a context produced by MarshalBinary
+func (sc *SessionContext) UnmarshalBinary(data []byte) error {
+	r := &reader{buf: data}
+	var c SessionContext
+	c.SessionID = bytes.Clone(r.bytes(r.int()))
+	c.GroupID = bytes.Clone(r.bytes(r.int()))
+	c.Epoch = r.uvarint()
+	c.AggregatorID = bytes.Clone(r.bytes(r.int()))
+	if r.err == nil && len(r.buf) != 0 {
+		r.fail()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*sc = c
+	return nil
+}
+
+// Equal reports whether both contexts identify the same session
+func (sc *SessionContext) Equal(other *SessionContext) bool {
+	if sc == nil || other == nil {
+		return sc == other
+	}
+	return bytes.Equal(sc.encode(), other.encode())
+}
@@ -0,0 +1,76 @@
+package scheme_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionContext(t *testing.T) {
+	once()
+	pub, _ := crt.Pub.MarshalBinaryCompress()
+	ctx := &scheme.SessionContext{
+		SessionID:    []byte("session-1"),
+		GroupID:      pub,
+		Epoch:        3,
+		AggregatorID: []byte("aggregator-1"),
+	}
+	data, err := ctx.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.SessionContext)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.True(t, ctx.Equal(decoded))
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrEncoding)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), scheme.ErrEncoding)
+
+	m := []byte("Hello World")
+	msg := scheme.NewMessage(m).WithContext(ctx)
+	sig := signMessage(t, scheme.DefaultSuite, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+		return s.SignMessage(msg, crt.Pub, B)
+	}, msg)
+	assert.True(t, sig.VerifyMessage(crt.Pub, msg))
+	assert.True(t, sig.VerifyMessage(crt.Pub, scheme.NewMessage(m).WithContext(decoded)))
+
+	// The signature commits to its context
+	assert.False(t, sig.VerifyBytes(crt.Pub, m))
+	other := *ctx
+	other.Epoch++
+	assert.False(t, sig.VerifyMessage(crt.Pub, scheme.NewMessage(m).WithContext(&other)))
+	other = *ctx
+	other.SessionID = []byte("session-2")
+	assert.False(t, ctx.Equal(&other))
+	assert.False(t, sig.VerifyMessage(crt.Pub, scheme.NewMessage(m).WithContext(&other)))
+
+	// The partial signatures of one session do not verify in another
+	T := crt.ThresholdT2
+	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
+	signs := make([]*bigint.Int, 0, T)
+	R := make([]group.Element, 0, T)
+	for i := 0; i < T; i++ {
+		signer := scheme.NewSigner(ei[i], di[i], crt.Remainder[i], crt.Pub, B[i])
+		s, r, err := signer.SignMessage(msg, crt.Pub, B)
+		assert.NoError(t, err)
+		signs = append(signs, s)
+		R = append(R, r)
+	}
+	U, err := crt.SigningCommitments(moduli[:T])
+	assert.NoError(t, err)
+	failed, err := scheme.VerifyPartialsMessage(scheme.DefaultSuite, scheme.NewMessage(m).WithContext(&other), signs, R, U, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrPartialSignature)
+	assert.Len(t, failed, T)
+
+	// Prehashed messages are bound the same way
+	digest, err := scheme.PrehashMessage(scheme.DefaultSuite, bytes.NewReader(m))
+	assert.NoError(t, err)
+	assert.False(t, sig.VerifyMessage(crt.Pub, digest.WithContext(ctx)))
+	sig = signMessage(t, scheme.DefaultSuite, func(s *scheme.Signer, B scheme.B) (*bigint.Int, group.Element, error) {
+		return s.SignMessage(digest.WithContext(ctx), crt.Pub, B)
+	}, digest.WithContext(ctx))
+	assert.True(t, sig.VerifyMessage(crt.Pub, digest.WithContext(decoded)))
+	assert.False(t, sig.VerifyMessage(crt.Pub, digest))
+	assert.False(t, sig.VerifyMessage(crt.Pub, msg))
+}
@@ -0,0 +1,224 @@
+package scheme
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrSignatureLength = errors.New("scheme: invalid signature length")
+	ErrSignaturePoint  = errors.New("scheme: invalid signature commitment R")
+	ErrSignatureScalar = errors.New("scheme: non-canonical signature scalar s")
+)
+
+// SignatureSize returns the length of an encoded signature in the group g:
+// group || suite || compressed R || s
+func SignatureSize(g Group) int {
+	params := g.Params()
+	return 2 + int(params.CompressedElementLength) + int(params.ScalarLength)
+}
+
+// Signature is an aggregated threshold schnorr signature (R, s)
+type Signature struct {
+	Suite SuiteID       // Hash suite of the challenge
+	R     group.Element // Commitment
+	S     group.Scalar  // Response
+}
+
+// AggregateSignature aggregates the partial signatures of the drones in B on
+// msg, P is the product of their moduli and h the hash suite the drones signed
+// with. See Aggregate.
+func AggregateSignature(h Hasher, msg Message, s []*bigint.Int, R group.Element, pub group.Element, P *bigint.Int) (*Signature, error) {
+	sS, err := Aggregate(h, msg, s, R, pub, P)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{Suite: h.ID(), R: R, S: sS}, nil
+}
+
+// Group returns the group of the signature, or nil if R is not set
+func (sig *Signature) Group() Group {
+	if sig.R == nil {
+		return nil
+	}
+	return groupOf(sig.R.Group())
+}
+
+// Verify the signature of m under the public key
+func (sig *Signature) Verify(pub group.Element, m string) bool {
+	if sig.R == nil || sig.S == nil || pub == nil {
+		return false
+	}
+	h, err := SuiteByID(sig.Suite)
+	if err != nil {
+		return false
+	}
+	return VerifyWithSuite(h, m, sig.S, sig.R, pub)
+}
+
+// VerifyBytes is Verify with a binary message
+func (sig *Signature) VerifyBytes(pub group.Element, m []byte) bool {
+	return sig.VerifyMessage(pub, NewMessage(m))
+}
+
+// VerifyReader verifies the signature of the message read from r in prehash
+// mode, the error is that of reading or of the unknown suite of the signature
+func (sig *Signature) VerifyReader(pub group.Element, r io.Reader) (bool, error) {
+	h, err := SuiteByID(sig.Suite)
+	if err != nil {
+		return false, err
+	}
+	msg, err := PrehashMessage(h, r)
+	if err != nil {
+		return false, err
+	}
+	return sig.VerifyMessage(pub, msg), nil
+}
+
+// VerifyMessage verifies the signature of a message that may be prehashed,
+// a message prehashed with another suite than that of the signature is rejected
+func (sig *Signature) VerifyMessage(pub group.Element, msg Message) bool {
+	if sig.R == nil || sig.S == nil || pub == nil {
+		return false
+	}
+	h, err := SuiteByID(sig.Suite)
+	if err != nil {
+		return false
+	}
+	return VerifyMessage(h, msg, sig.S, sig.R, pub)
+}
+
+// Marshal returns the canonical encoding group || suite || compressed R || s,
+// where s is encoded as defined by the group.
+func (sig *Signature) Marshal() ([]byte, error) {
+	if _, err := SuiteByID(sig.Suite); err != nil {
+		return nil, err
+	}
+	g := sig.Group()
+	if g == nil || sig.S == nil || groupOf(sig.S.Group()) != g {
+		return nil, ErrSignaturePoint
+	}
+	s, err := sig.S.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, SignatureSize(g))
+	buf = append(buf, byte(g.ID()), byte(sig.Suite))
+	buf = append(buf, elementBytes(sig.R)...)
+	buf = append(buf, s...)
+	return buf, nil
+}
+
+// Unmarshal decodes a signature produced by Marshal. It rejects encodings
+// of the wrong length, unknown groups and suites, R that is not the canonical
+// compressed encoding of a non-identity element and s that is not fully
+// reduced modulo the group order.
+func (sig *Signature) Unmarshal(data []byte) error {
+	if len(data) < 2 {
+		return ErrSignatureLength
+	}
+	g, err := GroupByID(GroupID(data[0]))
+	if err != nil {
+		return err
+	}
+	if len(data) != SignatureSize(g) {
+		return ErrSignatureLength
+	}
+	suite := SuiteID(data[1])
+	if _, err := SuiteByID(suite); err != nil {
+		return err
+	}
+	rEnd := 2 + int(g.Params().CompressedElementLength)
+	rb, sb := data[2:rEnd], data[rEnd:]
+
+	// Only the compressed form is canonical
+	R := g.NewElement()
+	if err := R.UnmarshalBinary(rb); err != nil || R.IsIdentity() || !bytes.Equal(elementBytes(R), rb) {
+		return ErrSignaturePoint
+	}
+
+	s := g.NewScalar()
+	if err := s.UnmarshalBinary(sb); err != nil {
+		return ErrSignatureScalar
+	}
+	if enc, _ := s.MarshalBinary(); !bytes.Equal(enc, sb) {
+		return ErrSignatureScalar
+	}
+
+	sig.Suite, sig.R, sig.S = suite, R, s
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (sig *Signature) MarshalBinary() ([]byte, error) {
+	return sig.Marshal()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (sig *Signature) UnmarshalBinary(data []byte) error {
+	return sig.Unmarshal(data)
+}
+
+// MarshalText implements encoding.TextMarshaler using hex
+func (sig *Signature) MarshalText() ([]byte, error) {
+	b, err := sig.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using hex
+func (sig *Signature) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return ErrSignatureLength
+	}
+	return sig.Unmarshal(b)
+}
+
+// String returns the hex encoding of the signature
+func (sig *Signature) String() string {
+	b, err := sig.MarshalText()
+	if err != nil {
+		return "<invalid signature>"
+	}
+	return string(b)
+}
+
+// Base64 returns the standard base64 encoding of the signature
+func (sig *Signature) Base64() (string, error) {
+	b, err := sig.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// ParseSignatureHex decodes a hex encoded signature
+func ParseSignatureHex(s string) (*Signature, error) {
+	sig := new(Signature)
+	if err := sig.UnmarshalText([]byte(s)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// ParseSignatureBase64 decodes a base64 encoded signature
+func ParseSignatureBase64(s string) (*Signature, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrSignatureLength
+	}
+	sig := new(Signature)
+	if err := sig.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
@@ -0,0 +1,111 @@
+package scheme_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/ecc/bls12381"
+	"github.com/cloudflare/circl/group"
+	"github.com/stretchr/testify/assert"
+)
+
+// thresholdSign signs m with the first ThresholdT2 drones
+func thresholdSign(m string) *scheme.Signature {
+	return thresholdSignWith(scheme.DefaultSuite, m)
+}
+
+// thresholdSignWith signs m with the first ThresholdT2 drones using the hash suite h
+func thresholdSignWith(h scheme.Hasher, m string) *scheme.Signature {
+	once()
+	T := crt.ThresholdT2
+	B := scheme.NewB(moduli[:T], Ei[:T], Di[:T])
+
+	P := new(bigint.Int).SetInt64(1)
+	signs := make([]*bigint.Int, 0, T)
+	var R group.Element
+	for i := 0; i < T; i++ {
+		signer := scheme.NewSigner(ei[i], di[i], crt.Remainder[i], crt.Pub, B[i])
+		signer.Suite = h
+		s, r, err := signer.Sign(m, crt.Pub, B)
+		if err != nil {
+			panic(err)
+		}
+		signs = append(signs, s)
+		R = r
+		P.Mul(P, moduli[i])
+	}
+	sig, err := scheme.AggregateSignature(h, scheme.NewMessage([]byte(m)), signs, R, crt.Pub, P)
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	m := "Hello World"
+	sig := thresholdSign(m)
+	assert.True(t, sig.Verify(crt.Pub, m))
+	assert.False(t, sig.Verify(crt.Pub, "Hello World!"))
+
+	data, err := sig.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, data, scheme.SignatureSize(scheme.BLS12381G1))
+
+	decoded := new(scheme.Signature)
+	assert.NoError(t, decoded.Unmarshal(data))
+	assert.True(t, decoded.Verify(crt.Pub, m))
+
+	fromHex, err := scheme.ParseSignatureHex(sig.String())
+	assert.NoError(t, err)
+	assert.True(t, fromHex.Verify(crt.Pub, m))
+
+	b64, err := sig.Base64()
+	assert.NoError(t, err)
+	fromB64, err := scheme.ParseSignatureBase64(b64)
+	assert.NoError(t, err)
+	assert.True(t, fromB64.Verify(crt.Pub, m))
+}
+
+func TestSignatureStrict(t *testing.T) {
+	sig := thresholdSign("Hello World")
+	data, _ := sig.Marshal()
+	decoded := new(scheme.Signature)
+
+	assert.ErrorIs(t, decoded.Unmarshal(data[1:]), scheme.ErrSignatureLength)
+	assert.ErrorIs(t, decoded.Unmarshal(append(data, 0)), scheme.ErrSignatureLength)
+
+	// s = order is not reduced
+	rEnd := 2 + bls12381.G1SizeCompressed
+	order := append([]byte{}, data[:rEnd]...)
+	order = append(order, bls12381.Order()...)
+	assert.ErrorIs(t, decoded.Unmarshal(order), scheme.ErrSignatureScalar)
+
+	// The identity and points off the curve are rejected
+	identity := new(bls12381.G1)
+	identity.SetIdentity()
+	bad := append([]byte{data[0], data[1]}, identity.BytesCompressed()...)
+	bad = append(bad, data[rEnd:]...)
+	assert.ErrorIs(t, decoded.Unmarshal(bad), scheme.ErrSignaturePoint)
+	bad = append([]byte{}, data...)
+	bad[5] ^= 0x01
+	assert.ErrorIs(t, decoded.Unmarshal(bad), scheme.ErrSignaturePoint)
+
+	// The uncompressed flag is not canonical
+	bad = append([]byte{}, data...)
+	bad[2] &= 0x7f
+	assert.ErrorIs(t, decoded.Unmarshal(bad), scheme.ErrSignaturePoint)
+
+	// Unknown groups and suites are rejected
+	bad = append([]byte{}, data...)
+	bad[0] = 0xff
+	assert.ErrorIs(t, decoded.Unmarshal(bad), scheme.ErrUnknownGroup)
+	bad = append([]byte{}, data...)
+	bad[1] = 0xff
+	assert.ErrorIs(t, decoded.Unmarshal(bad), scheme.ErrUnknownSuite)
+
+	_, err := scheme.ParseSignatureHex(strings.ToUpper(hex.EncodeToString(data)) + "zz")
+	assert.Error(t, err)
+}
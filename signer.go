@@ -1,114 +1,177 @@
 package scheme
 
 import (
-	"crypto/sha256"
+	"io"
+	"slices"
 
-	"github.com/cloudflare/circl/ecc/bls12381"
-	"github.com/ncw/gmp"
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
 )
 
 // Parameters owned by the signer
 type Signer struct {
-	e     *bls12381.Scalar // e
-	d     *bls12381.Scalar // d
-	s     *gmp.Int         // remainder
-	Pub   *bls12381.G1     // Public key
+	e        group.Scalar  // e
+	d        group.Scalar  // d
+	pool     *NoncePool    // Nonce pairs, nil if e and d are static
+	nonceKey []byte        // Secret key of the derived nonce pairs
+	s        *bigint.Int   // remainder
+	Pub      group.Element // Public key
+	Suite    Hasher        // Hash suite of rho, the challenge and the derived nonces
+	Rand     io.Reader     // Randomness hedging the derived nonces, nil for deterministic ones
+	lambda   *lambda       // CRT coefficient of the drone in the moduli of the last B
 	BItem                  // BItem
 }
 
-func NewSigner(e, d *bls12381.Scalar, s *gmp.Int, pub *bls12381.G1, b BItem) *Signer {
-	return &Signer{e: e, d: d, s: s, Pub: pub, BItem: b}
+// NewSigner creates a signer with the static nonce pair (e, d).
+//
+// Deprecated: the pair is used for every signature, two signatures with the
+// same B leak the remainder. Use NewPoolSigner.
+func NewSigner(e, d group.Scalar, s *bigint.Int, pub group.Element, b BItem) *Signer {
+	return &Signer{e: e, d: d, s: s, Pub: pub, Suite: DefaultSuite, BItem: b}
+}
+
+// NewPoolSigner creates a signer for the drone with the modulus P that takes
+// the nonce pair of every signature from the pool
+func NewPoolSigner(pool *NoncePool, s *bigint.Int, pub group.Element, P *bigint.Int) *Signer {
+	return &Signer{pool: pool, s: s, Pub: pub, Suite: DefaultSuite, BItem: BItem{P: P}}
 }
 
 // Sign returns the signature of the i-th drone
 // Threshold schnorr signature = (s, R)
-func (p *Signer) Sign(m string, pub *bls12381.G1, B B) (*gmp.Int, *bls12381.G1) {
+//
+// A pool signer uses the nonce pair of its item in B and spends it,
+// it refuses to sign with a spent or unknown nonce.
+func (p *Signer) Sign(m string, pub group.Element, B B) (*bigint.Int, group.Element, error) {
+	return p.SignMessage(NewMessage([]byte(m)), pub, B)
+}
+
+// SignBytes is Sign with a binary message
+func (p *Signer) SignBytes(m []byte, pub group.Element, B B) (*bigint.Int, group.Element, error) {
+	return p.SignMessage(NewMessage(m), pub, B)
+}
+
+// SignReader signs the message read from r in prehash mode,
+// see PrehashMessage
+func (p *Signer) SignReader(r io.Reader, pub group.Element, B B) (*bigint.Int, group.Element, error) {
+	if p.destroyed() {
+		return nil, nil, ErrSignerDestroyed
+	}
+	msg, err := PrehashMessage(p.Suite, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.SignMessage(msg, pub, B)
+}
+
+// SignMessage is Sign with a message that may be prehashed, all the drones
+// of B must sign the same message in the same mode
+func (p *Signer) SignMessage(msg Message, pub group.Element, B B) (*bigint.Int, group.Element, error) {
+	if p.destroyed() {
+		return nil, nil, ErrSignerDestroyed
+	}
+	if err := msg.check(p.Suite); err != nil {
+		return nil, nil, err
+	}
+	g := groupOf(pub.Group())
+	i := slices.IndexFunc(B, func(item BItem) bool { return item.P.Cmp(p.P) == 0 })
+	if i < 0 {
+		return nil, nil, ErrSignerNotInB
+	}
+	if err := p.coefficient(B, i); err != nil {
+		return nil, nil, err
+	}
+	e, d := p.e, p.d
+	if p.pool != nil {
+		var err error
+		if d, e, err = p.pool.take(B[i]); err != nil {
+			return nil, nil, err
+		}
+		defer zeroScalar(d)
+		defer zeroScalar(e)
+	}
+
 	// rho
-	rho := p.rho(m, pub, B)
+	rho := p.rho(p.Suite, msg, pub, B)
 
 	// Commitment R
 	R := B.commitment(rho)
 
 	// erho = e * rho
-	erho := new(bls12381.Scalar)
-	erho.Mul(p.e, rho)
+	erho := g.NewScalar()
+	erho.Mul(e, rho)
+	defer zeroScalar(erho)
 
 	// k = d + e * rho
-	k := new(bls12381.Scalar)
-	k.Add(p.d, erho)
-
-	cContent := make([]byte, 0)
-	cContent = append(cContent, []byte(pub.BytesCompressed())...)
-	cContent = append(cContent, []byte(m)...)
-	cContent = append(cContent, R.Bytes()...)
-	cBytes := sha256.Sum256(cContent)
+	k := g.NewScalar()
+	k.Add(d, erho)
+	defer zeroScalar(k)
 
 	// c = H(m || R)
-	cScalar := new(bls12381.Scalar)
-	cScalar.SetBytes(cBytes[:])
-	c := ScalarToGmp(cScalar)
-
-	gmpK := ScalarToGmp(k)
+	c := challenge(p.Suite, msg, R, pub)
 
-	// lambda = Q * Q^-1
-	lambda := p.lambda(B)
+	// u = lambda * s mod P, the share of the drone in the secret of B
+	gmpU := p.lambda.share(p.s)
+	defer bigint.Clear(gmpU)
+	u := IntToScalar(g, gmpU)
+	defer zeroScalar(u)
 
-	// sc = lambda * s * c
-	sc := new(gmp.Int).SetInt64(1)
-	sc.Mul(sc, lambda)
-	sc.Mul(sc, p.s)
-	sc.Mul(sc, c)
+	// s = k + c * u mod q, k blinds u
+	sc := g.NewScalar().Mul(c, u)
+	sc.Add(sc, k)
+	defer zeroScalar(sc)
 
-	// s = k + sc
-	s := new(gmp.Int).SetInt64(0)
-	s.Add(gmpK, sc)
-
-	return s, R
+	return g.ScalarToInt(sc), R, nil
 }
 
-// lambda returns the lambda of the drone
-// lambda = Q * Q^-1
-func (p *Signer) lambda(B B) *gmp.Int {
-	Q := new(gmp.Int).SetInt64(1)
-	for _, item := range B {
-		Q.Mul(Q, item.P)
+// Refresh applies the offset of a share refresh to the remainder
+func (p *Signer) Refresh(offset *bigint.Int) {
+	if p.destroyed() {
+		return
 	}
-	Q.Div(Q, p.P)
+	s := RefreshShare(p.P, p.s, offset)
+	bigint.Clear(p.s)
+	p.s = s
+}
 
-	QInv := new(gmp.Int).ModInverse(Q, p.P)
-	res := new(gmp.Int).Mul(Q, QInv)
-	Q.Clear()
-	QInv.Clear()
-	return res
+// coefficient keeps the CRT coefficient of the i-th drone of B, it is reused
+// by the next signature with the same moduli
+func (p *Signer) coefficient(B B, i int) error {
+	if p.lambda.matches(B, i) {
+		return nil
+	}
+	l, err := lambdaOf(B, i)
+	if err != nil {
+		return err
+	}
+	p.lambda = l
+	return nil
 }
 
 type BItem struct {
-	P *gmp.Int     // The prime number
-	E *bls12381.G1 // E
-	D *bls12381.G1 // D
+	P     *bigint.Int   // The prime number
+	E     group.Element // E
+	D     group.Element // D
+	Nonce uint64        // Index of the nonce pair in the pool of the drone
 }
 
 // rho returns the rho of the i-th drone
-// rho = H(m || E || D)
-func (item BItem) rho(m string, pub *bls12381.G1, b B) *bls12381.Scalar {
-	bytesM := []byte(m)
-	rho := new(bls12381.Scalar)
-	sha := sha256.New()
-	sha.Write(pub.BytesCompressed())
-	sha.Write(bytesM)
+// rho = H_rho(pub || m || E_1 || D_1 || ... || E_n || D_n)
+// where m is the digest and the prehash flag in prehash mode
+func (item BItem) rho(h Hasher, msg Message, pub group.Element, b B) group.Scalar {
+	parts := make([][]byte, 0, 3+2*len(b))
+	parts = append(parts, elementBytes(pub))
+	parts = append(parts, msg.parts()...)
 	for i := 0; i < len(b); i++ {
-		sha.Write(b[i].E.BytesCompressed())
-		sha.Write(b[i].D.BytesCompressed())
+		parts = append(parts, elementBytes(b[i].E), elementBytes(b[i].D))
 	}
-	rho.SetBytes(sha.Sum(nil))
-	return rho
+	return h.HashToScalar(groupOf(pub.Group()), dstRho, parts...)
 }
 
 // B is a list of all the drones to be signatured
 type B []BItem
 
 // NewB creates a new B
-func NewB(moduli []*gmp.Int, Ei []*bls12381.G1, Di []*bls12381.G1) B {
+func NewB(moduli []*bigint.Int, Ei []group.Element, Di []group.Element) B {
 	b := make(B, 0, len(moduli))
 	for i := 0; i < len(moduli); i++ {
 		b = append(b, BItem{P: moduli[i], E: Ei[i], D: Di[i]})
@@ -117,61 +180,96 @@ func NewB(moduli []*gmp.Int, Ei []*bls12381.G1, Di []*bls12381.G1) B {
 }
 
 // Commitment returns the commitment(R) of the i-th drone
-func (b B) commitment(rho *bls12381.Scalar) *bls12381.G1 {
-	R := new(bls12381.G1)
-	R.SetIdentity()
-
-	sumE := new(bls12381.G1)
-	sumE.SetIdentity()
+func (b B) commitment(rho group.Scalar) group.Element {
+	g := rho.Group()
+	R := g.Identity()
+	sumE := g.Identity()
 
 	for _, item := range b {
 		R.Add(R, item.D)
 		sumE.Add(sumE, item.E)
 	}
-	sumE.ScalarMult(rho, sumE)
+	sumE.Mul(sumE, rho)
 	R.Add(R, sumE)
 	return R
 }
 
-// Aggregate the signature
-func Aggregate(s []*gmp.Int, R *bls12381.G1, P *gmp.Int) (*bls12381.Scalar, *bls12381.G1) {
-	order := new(gmp.Int).SetBytes(bls12381.Order()[:])
-	defer order.Clear()
-	sAgg := new(gmp.Int).SetInt64(0)
+// Aggregate the partial signatures of the drones in B on msg, P is the
+// product of their moduli. The shares of the drones sum to S + j * P for a
+// carry j < len(s), so sum(s_i) = k + c * (S + j * P) mod q and the carry is
+// the one for which the signature verifies under pub.
+func Aggregate(h Hasher, msg Message, s []*bigint.Int, R group.Element, pub group.Element, P *bigint.Int) (group.Scalar, error) {
+	g := groupOf(pub.Group())
+	if !sameGroup(g, R) || msg.check(h) != nil {
+		return nil, ErrAggregate
+	}
+	sum := g.NewScalar()
 	for _, si := range s {
-		sAgg.Add(sAgg, si)
+		sum.Add(sum, IntToScalar(g, si))
+	}
+	c := challenge(h, msg, R, pub)
+
+	// want = R + c * pub, step = c * P
+	want := g.NewElement().Mul(pub, c)
+	want.Add(want, R)
+	step := g.NewScalar().Mul(c, IntToScalar(g, P))
+	stepG := g.NewElement().MulGen(step)
+	stepG.Neg(stepG)
+	X := g.NewElement().MulGen(sum)
+	for j := 0; j < len(s); j++ {
+		if X.IsEqual(want) {
+			return sum, nil
+		}
+		sum.Sub(sum, step)
+		X.Add(X, stepG)
 	}
-	sAgg.Mod(sAgg, P)
-	sAgg.Mod(sAgg, order)
-	sS := new(bls12381.Scalar)
-	sS.SetBytes(sAgg.Bytes())
-	return sS, R
+	return nil, ErrAggregate
 }
 
-// Verify the signature
-func Verify(m string, s *bls12381.Scalar, R *bls12381.G1, pub *bls12381.G1) bool {
-	// c = H(m || R)
-	hasher := sha256.New()
-	hasher.Write(pub.BytesCompressed())
-	hasher.Write([]byte(m))
-	hasher.Write(R.Bytes())
+// Verify the signature with the default hash suite
+func Verify(m string, s group.Scalar, R group.Element, pub group.Element) bool {
+	return VerifyWithSuite(DefaultSuite, m, s, R, pub)
+}
+
+// VerifyWithSuite verifies the signature with the given hash suite
+func VerifyWithSuite(h Hasher, m string, s group.Scalar, R group.Element, pub group.Element) bool {
+	return VerifyMessage(h, NewMessage([]byte(m)), s, R, pub)
+}
+
+// VerifyMessage verifies the signature of a message that may be prehashed
+// with the given hash suite
+func VerifyMessage(h Hasher, msg Message, s group.Scalar, R group.Element, pub group.Element) bool {
+	g := groupOf(pub.Group())
+	if !sameGroup(g, R) || groupOf(s.Group()) != g || msg.check(h) != nil {
+		return false
+	}
 
-	c := new(bls12381.Scalar)
-	c.SetBytes(hasher.Sum(nil))
+	// c = H(m || R)
+	c := challenge(h, msg, R, pub)
 
 	// left = s * G
-	left := new(bls12381.G1)
-	left.ScalarMult(s, bls12381.G1Generator())
+	left := g.NewElement()
+	left.MulGen(s)
 
 	// right = R + c * pub
-	right := new(bls12381.G1)
-	right.SetIdentity()
+	right := g.Identity()
 	right.Add(right, R)
 
 	// cPub = c * pub
-	cPub := new(bls12381.G1)
-	cPub.ScalarMult(c, pub)
+	cPub := g.NewElement()
+	cPub.Mul(pub, c)
 	right.Add(right, cPub)
 
 	return left.IsEqual(right)
 }
+
+// challenge returns c = H_challenge(pub || m || R)
+// where m is the digest and the prehash flag in prehash mode
+func challenge(h Hasher, msg Message, R group.Element, pub group.Element) group.Scalar {
+	g := groupOf(pub.Group())
+	parts := make([][]byte, 0, 4)
+	parts = append(parts, elementBytes(pub))
+	parts = append(parts, msg.parts()...)
+	parts = append(parts, elementBytes(R))
+	return h.HashToScalar(g, dstChallenge, parts...)
+}
@@ -6,16 +6,16 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/52funny/scheme/bigint"
 	"github.com/cloudflare/circl/ecc/bls12381"
-	"github.com/ncw/gmp"
 )
 
 // Number of goroutines
 const GOROUTINES int = 16
 
 // Generate a random prime number with the specified number of bits
-func GeneratePrime(bits int) *gmp.Int {
-	x := new(gmp.Int)
+func GeneratePrime(bits int) *bigint.Int {
+	x := new(bigint.Int)
 	for {
 		buf := make([]byte, bits/8)
 		rand.Read(buf)
@@ -34,54 +34,52 @@ func GeneratePrime(bits int) *gmp.Int {
 }
 
 // Generate a random prime number in the range [n / (n + 1) * 2 ** bits, 2 ** bits)
-func GenerateRangePrime(bits int, n int) *gmp.Int {
+func GenerateRangePrime(bits int, n int) *bigint.Int {
 	// mst = 2 ** bits
-	mst := new(gmp.Int)
-	mst.Lsh(gmp.NewInt(1), uint(bits))
+	mst := new(bigint.Int)
+	mst.Lsh(bigint.NewInt(1), uint(bits))
 
-	// lo = n / (n + 1) * 2 ** bits
-	// hi = 2 ** bits
-	lo, hi := new(gmp.Rat), new(gmp.Rat)
-	lo.SetNum(mst)
-	hi.SetNum(mst)
-	lo.Mul(lo, gmp.NewRat(int64(n), int64(n+1)))
+	// lo <= x  <=>  n * 2 ** bits <= (n + 1) * x
+	// x < hi  <=>  x < 2 ** bits
+	lo := new(bigint.Int).Mul(mst, bigint.NewInt(int64(n)))
+	n1 := bigint.NewInt(int64(n + 1))
 
 	// Generate a random number in the range [lo, hi)
-	x := new(gmp.Int)
+	x := new(bigint.Int)
+	scaled := new(bigint.Int)
 	for {
 		x = GeneratePrime(bits)
-		xRat := new(gmp.Rat).SetNum(x)
+		scaled.Mul(x, n1)
 		// Make sure lo <= x < hi
-		if xRat.Cmp(hi) == -1 && xRat.Cmp(lo) >= 0 {
+		if x.Cmp(mst) == -1 && scaled.Cmp(lo) >= 0 {
 			break
 		}
-		xRat.Clear()
 	}
 	// Free the memory
-	mst.Clear()
-	lo.Clear()
-	hi.Clear()
+	bigint.Clear(mst)
+	bigint.Clear(lo)
+	bigint.Clear(scaled)
 	return x
 }
 
-func Compact(arr []*gmp.Int) []*gmp.Int {
+func Compact(arr []*bigint.Int) []*bigint.Int {
 	// Remove duplicates
-	unique := make(map[string]*gmp.Int)
+	unique := make(map[string]*bigint.Int)
 	for _, x := range arr {
 		unique[x.String()] = x
 	}
 
 	// Convert map to slice
-	compacted := make([]*gmp.Int, 0, len(unique))
+	compacted := make([]*bigint.Int, 0, len(unique))
 	for _, x := range unique {
 		compacted = append(compacted, x)
 	}
 	return compacted
 }
 
-func GenerateNumber(weightOpts []int, n int) []*gmp.Int {
+func GenerateNumber(weightOpts []int, n int) []*bigint.Int {
 	ch := make(chan int, n)
-	product := make(chan *gmp.Int, n)
+	product := make(chan *bigint.Int, n)
 	sets := sync.Map{}
 
 	for i := 0; i < GOROUTINES; i++ {
@@ -107,7 +105,7 @@ func GenerateNumber(weightOpts []int, n int) []*gmp.Int {
 		ch <- w
 	}
 
-	moduli := make([]*gmp.Int, 0, n)
+	moduli := make([]*bigint.Int, 0, n)
 	for i := 0; i < n; i++ {
 		p := <-product
 		moduli = append(moduli, p)
@@ -116,23 +114,26 @@ func GenerateNumber(weightOpts []int, n int) []*gmp.Int {
 	close(product)
 
 	// Sort the moduli in ascending order
-	slices.SortFunc(moduli, func(x, y *gmp.Int) int {
+	slices.SortFunc(moduli, func(x, y *bigint.Int) int {
 		return x.Cmp(y)
 	})
 	return moduli
 }
 
 // Conver the GMP integer to a bls12381 scalar
-func GmpToScalar(g *gmp.Int) *bls12381.Scalar {
+//
+// Deprecated: use IntToScalar(BLS12381G1, g), which works with either backend
+// and any group.
+func GmpToScalar(g *bigint.Int) *bls12381.Scalar {
 	// Copy the scalar and reduce it modulo the order of the curve
-	gCopy := new(gmp.Int).Set(g)
+	gCopy := new(bigint.Int).Set(g)
 	order := bls12381.Order()
-	orderGmp := new(gmp.Int).SetBytes(order)
+	orderGmp := new(bigint.Int).SetBytes(order)
 	gCopy.Mod(gCopy, orderGmp)
 	buf := gCopy.Bytes()
 
 	// Free the gCopy memory
-	gCopy.Clear()
+	bigint.Clear(gCopy)
 
 	scalar := new(bls12381.Scalar)
 	scalar.SetBytes(buf)
@@ -140,10 +141,12 @@ func GmpToScalar(g *gmp.Int) *bls12381.Scalar {
 }
 
 // Convert the bls12381 scalar to a GMP integer
-func ScalarToGmp(scalar *bls12381.Scalar) *gmp.Int {
+//
+// Deprecated: use ScalarToInt, which works with either backend and any group.
+func ScalarToGmp(scalar *bls12381.Scalar) *bigint.Int {
 	buf := scalar.String()
 	// buf[2:] is remove the 0x prefix
-	g, _ := new(gmp.Int).SetString(buf[2:], 16)
+	g, _ := new(bigint.Int).SetString(buf[2:], 16)
 	return g
 }
 
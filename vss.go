@@ -0,0 +1,181 @@
+package scheme
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var (
+	ErrDealingProof  = errors.New("scheme: malformed dealing proof")
+	ErrShareMismatch = errors.New("scheme: share is not consistent with the dealing")
+)
+
+// DealingProof is published by the dealer next to the public key, so that every
+// drone can check its share on receipt. For every drone j it holds the
+// commitment A_j = a_j * G to the CRT coefficient a_j = Q_j^-1 * r_j mod m_j,
+// where Q_j is the product of all the other moduli.
+//
+// a_j < m_j is found from A_j by a search of about sqrt(m_j) steps, and r_j
+// with it, so the proof leaks the shares of small moduli such as 16 bit ones.
+// Only publish the proof of moduli with at least 256 bits.
+type DealingProof struct {
+	Moduli      []*bigint.Int   // The moduli of all the drones
+	Commitments []group.Element // The coefficient commitments A_j
+}
+
+// Proof returns the dealing proof of the sharing
+func (crt *CRTSharing) Proof() *DealingProof {
+	return dealingProof(crt.Group, crt.Moduli, crt.Remainder)
+}
+
+// dealingProof returns the dealing proof of the remainders of the moduli
+func dealingProof(g Group, moduli, remainder []*bigint.Int) *DealingProof {
+	P := product(moduli)
+	defer bigint.Clear(P)
+	A := make([]group.Element, 0, len(moduli))
+	for i, m := range moduli {
+		a := coefficient(P, m, remainder[i])
+		A = append(A, g.NewElement().MulGen(IntToScalar(g, a)))
+		bigint.Clear(a)
+	}
+	return &DealingProof{Moduli: slices.Clone(moduli), Commitments: A}
+}
+
+// VerifyShare checks that the remainder of modulus is a share of the secret
+// behind the public key pub.
+//
+// The drone checks its own commitment A_i, and that the coefficients of all
+// the drones recover the secret: S = sum(Q_j * a_j) - k * P for some
+// 0 <= k < n, where P is the product of all the moduli, so sum(Q_j * A_j) must
+// equal pub + k * P * G.
+//
+// The check holds modulo the group order q: A_j only commits to a_j mod q.
+// It binds the shares when all the moduli are below q. With moduli of at least
+// q, such as the 256 bit and larger moduli that hide the commitments on a
+// 255 bit group, a dealer may commit to a_j >= m_j for the other drones, which
+// only range proofs catch. The moduli are not checked to be pairwise coprime,
+// which takes O(n^2) gcds per drone: only the modulus of the drone is checked
+// to be coprime to the others, the moduli of validated GroupParams are.
+func VerifyShare(pub group.Element, modulus, remainder *bigint.Int, proof *DealingProof) error {
+	g := groupOf(pub.Group())
+	if proof == nil || len(proof.Moduli) == 0 || len(proof.Moduli) != len(proof.Commitments) ||
+		!sameGroup(g, proof.Commitments...) {
+		return ErrDealingProof
+	}
+	one := bigint.NewInt(1)
+	for j, m := range proof.Moduli {
+		if m == nil || m.Cmp(one) != 1 {
+			return fmt.Errorf("%w: invalid modulus at index %d", ErrDealingProof, j)
+		}
+	}
+	i := slices.IndexFunc(proof.Moduli, func(m *bigint.Int) bool { return modulus != nil && m.Cmp(modulus) == 0 })
+	if i < 0 {
+		return fmt.Errorf("%w: modulus is not in the dealing", ErrShareMismatch)
+	}
+	if remainder == nil || remainder.Sign() < 0 || remainder.Cmp(modulus) >= 0 {
+		return fmt.Errorf("%w: remainder out of range", ErrShareMismatch)
+	}
+
+	P := product(proof.Moduli)
+	defer bigint.Clear(P)
+
+	// A_i = a_i * G
+	a := coefficient(P, modulus, remainder)
+	if a == nil {
+		return fmt.Errorf("%w: modulus is not coprime to the others", ErrDealingProof)
+	}
+	Ai := g.NewElement().MulGen(IntToScalar(g, a))
+	bigint.Clear(a)
+	if !Ai.IsEqual(proof.Commitments[i]) {
+		return fmt.Errorf("%w: commitment of the drone differs", ErrShareMismatch)
+	}
+
+	// X = sum(Q_j * A_j) - pub
+	scalars := make([]group.Scalar, 0, len(proof.Moduli))
+	Q := new(bigint.Int)
+	defer bigint.Clear(Q)
+	for _, m := range proof.Moduli {
+		Q.Div(P, m)
+		scalars = append(scalars, IntToScalar(g, Q))
+	}
+	X := multiScalarMult(g, scalars, proof.Commitments)
+	X.Add(X, g.NewElement().Neg(pub))
+
+	// X = k * P * G for some 0 <= k < n
+	step := g.NewElement().MulGen(IntToScalar(g, P))
+	kP := g.Identity()
+	for k := 0; k < len(proof.Moduli); k++ {
+		if X.IsEqual(kP) {
+			return nil
+		}
+		kP.Add(kP, step)
+	}
+	return ErrShareMismatch
+}
+
+// coefficient returns a = Q^-1 * r mod m with Q = P / m, or nil if m is not
+// coprime to Q
+func coefficient(P, m, r *bigint.Int) *bigint.Int {
+	Q := new(bigint.Int).Div(P, m)
+	defer bigint.Clear(Q)
+	a := modInverse(Q, m)
+	if a == nil {
+		return nil
+	}
+	a.Mul(a, r)
+	return a.Mod(a, m)
+}
+
+// MarshalBinary encodes the proof as
+//
+//	group || count || (modulus || compressed Y)[count]
+func (proof *DealingProof) MarshalBinary() ([]byte, error) {
+	if len(proof.Moduli) == 0 || len(proof.Moduli) != len(proof.Commitments) {
+		return nil, ErrDealingProof
+	}
+	g := groupOf(proof.Commitments[0].Group())
+	if !sameGroup(g, proof.Commitments...) {
+		return nil, ErrDealingProof
+	}
+	buf := []byte{byte(g.ID())}
+	buf = binary.AppendUvarint(buf, uint64(len(proof.Moduli)))
+	for i, m := range proof.Moduli {
+		buf = appendInt(buf, m)
+		buf = append(buf, elementBytes(proof.Commitments[i])...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a proof produced by MarshalBinary
+func (proof *DealingProof) UnmarshalBinary(data []byte) error {
+	r := &reader{buf: data}
+	g, err := GroupByID(GroupID(r.byte()))
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return err
+	}
+	n := r.int()
+	if r.err == nil && (n == 0 || n > len(r.buf)) {
+		r.fail()
+	}
+	var p DealingProof
+	for i := 0; i < n && r.err == nil; i++ {
+		p.Moduli = append(p.Moduli, r.bigInt())
+		p.Commitments = append(p.Commitments, r.element(g))
+	}
+	if r.err == nil && len(r.buf) != 0 {
+		r.fail()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,53 @@
+package scheme_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyShare(t *testing.T) {
+	once()
+	proof := crt.Proof()
+	assert.Len(t, proof.Moduli, crt.N)
+	for i := range crt.Moduli {
+		assert.NoError(t, scheme.VerifyShare(crt.Pub, crt.Moduli[i], crt.Remainder[i], proof), "index %d", i)
+	}
+
+	// A wrong remainder
+	last := crt.N - 1
+	bad := new(bigint.Int).Add(crt.Remainder[last], bigint.NewInt(1))
+	bad.Mod(bad, crt.Moduli[last])
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], bad, proof), scheme.ErrShareMismatch)
+	bad = new(bigint.Int).Add(crt.Remainder[0], bigint.NewInt(1))
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[0], bad, proof), scheme.ErrShareMismatch)
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], crt.Moduli[last], proof), scheme.ErrShareMismatch)
+
+	// A wrong public key
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Group.Generator(), crt.Moduli[last], crt.Remainder[last], proof), scheme.ErrShareMismatch)
+
+	// The proof survives encoding
+	data, err := proof.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(scheme.DealingProof)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.NoError(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], crt.Remainder[last], decoded))
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), scheme.ErrEncoding)
+
+	// Moduli outside of the dealing
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, bigint.NewInt(7), bigint.NewInt(1), proof), scheme.ErrShareMismatch)
+
+	// A tampered commitment of another drone
+	decoded.Commitments[1] = decoded.Commitments[0]
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], crt.Remainder[last], decoded), scheme.ErrShareMismatch)
+
+	// A modulus of the drone that is not coprime to the others
+	shared := &scheme.DealingProof{Moduli: slices.Clone(proof.Moduli), Commitments: proof.Commitments}
+	shared.Moduli[0] = crt.Moduli[last]
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], crt.Remainder[last], shared), scheme.ErrDealingProof)
+	shared.Moduli[0] = bigint.NewInt(1)
+	assert.ErrorIs(t, scheme.VerifyShare(crt.Pub, crt.Moduli[last], crt.Remainder[last], shared), scheme.ErrDealingProof)
+}
@@ -0,0 +1,93 @@
+package scheme
+
+import (
+	"errors"
+
+	"github.com/52funny/scheme/bigint"
+	"github.com/cloudflare/circl/group"
+)
+
+var ErrSignerDestroyed = errors.New("scheme: signer is destroyed")
+
+// Destroy overwrites the remainder, the nonce pairs and the nonce key of the
+// signer, including the unspent pairs of its pool. A destroyed signer refuses
+// to sign and to derive nonces.
+func (p *Signer) Destroy() {
+	if p.s != nil {
+		bigint.Clear(p.s)
+		p.s = nil
+	}
+	zeroScalar(p.e)
+	zeroScalar(p.d)
+	p.e, p.d = nil, nil
+	clear(p.nonceKey)
+	p.nonceKey = nil
+	if p.pool != nil {
+		p.pool.Wipe()
+	}
+	p.lambda = nil
+}
+
+// destroyed reports whether Destroy was called
+func (p *Signer) destroyed() bool {
+	return p.s == nil
+}
+
+// Wipe overwrites and drops all the unspent nonce pairs, their indices are
+// never handed out again
+func (np *NoncePool) Wipe() {
+	np.mux.Lock()
+	defer np.mux.Unlock()
+	for i, pair := range np.nonces {
+		zeroScalar(pair.d)
+		zeroScalar(pair.e)
+		delete(np.nonces, i)
+	}
+}
+
+// Wipe overwrites the secret and the remainders and drops them,
+// only the GroupParams are left afterwards
+func (crt *CRTSharing) Wipe() {
+	if crt.Secret != nil {
+		bigint.Clear(crt.Secret)
+		crt.Secret = nil
+	}
+	for _, r := range crt.Remainder {
+		bigint.Clear(r)
+	}
+	crt.Remainder = nil
+}
+
+// DropSecret overwrites and drops the secret, the remainders stay so the
+// shares can still be handed out. RestoreSecret brings the secret back for
+// Refresh, Enroll and Revoke.
+func (crt *CRTSharing) DropSecret() {
+	if crt.Secret != nil {
+		bigint.Clear(crt.Secret)
+		crt.Secret = nil
+	}
+}
+
+// RestoreSecret reconstructs the secret from the remainders of all the moduli
+// after DropSecret. It fails with ErrNoSecret once the sharing is wiped.
+func (crt *CRTSharing) RestoreSecret() error {
+	if crt.Secret != nil {
+		return nil
+	}
+	if len(crt.Remainder) != len(crt.Moduli) {
+		return ErrNoSecret
+	}
+	S := ReconstructSecret(crt.Moduli, crt.Remainder)
+	if S == nil {
+		return ErrNoSecret
+	}
+	crt.Secret = S
+	return nil
+}
+
+// zeroScalar overwrites the scalar with zero
+func zeroScalar(s group.Scalar) {
+	if s != nil {
+		s.SetUint64(0)
+	}
+}
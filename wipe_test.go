@@ -0,0 +1,87 @@
+package scheme_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/52funny/scheme"
+	"github.com/52funny/scheme/bigint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignerDestroy(t *testing.T) {
+	once()
+	g := crt.Group
+	m := "Hello World"
+
+	// A pool signer loses its remainder and its unspent nonce pairs
+	remainder := new(bigint.Int).Set(crt.Remainder[0])
+	pool := scheme.NewNoncePool(g)
+	c := pool.Generate(2)[0]
+	B := scheme.B{{P: moduli[0], E: c.E, D: c.D, Nonce: c.Index}}
+	signer := scheme.NewDerivedSigner([]byte("nonce key"), pool, remainder, crt.Pub, moduli[0])
+	signer.Destroy()
+	assert.Equal(t, 0, remainder.Sign())
+	assert.Equal(t, 0, remainder.BitLen())
+	assert.Equal(t, 0, pool.Len())
+	_, _, err := signer.Sign(m, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrSignerDestroyed)
+	_, err = signer.DeriveNonce(m, moduli[:1], []byte("session"))
+	assert.ErrorIs(t, err, scheme.ErrSignerDestroyed)
+	signer.Refresh(bigint.NewInt(1))
+	_, _, err = signer.Sign(m, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrSignerDestroyed)
+
+	// The static nonce pair is zeroed as well
+	e, d := g.RandomNonZeroScalar(rand.Reader), g.RandomNonZeroScalar(rand.Reader)
+	B = scheme.NewB(moduli[:1], Ei[:1], Di[:1])
+	signer = scheme.NewSigner(e, d, new(bigint.Int).Set(crt.Remainder[0]), crt.Pub, B[0])
+	signer.Destroy()
+	assert.True(t, e.IsZero())
+	assert.True(t, d.IsZero())
+	_, _, err = signer.Sign(m, crt.Pub, B)
+	assert.ErrorIs(t, err, scheme.ErrSignerDestroyed)
+}
+
+func TestCRTSharingWipe(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 10)
+	sharing := scheme.NewCRTSharing(10, 3, mod)
+	secret := sharing.Secret
+	remainder := sharing.Remainder
+	sharing.Wipe()
+	assert.Nil(t, sharing.Secret)
+	assert.Nil(t, sharing.Remainder)
+	assert.Equal(t, 0, secret.BitLen())
+	for _, r := range remainder {
+		assert.Equal(t, 0, r.BitLen())
+	}
+
+	// The public view stays usable
+	ok, _ := sharing.CanSign(mod[:sharing.ThresholdT2])
+	assert.True(t, ok)
+	_, err := sharing.Refresh()
+	assert.ErrorIs(t, err, scheme.ErrNoSecret)
+}
+
+func TestCRTSharingDropSecret(t *testing.T) {
+	mod := scheme.GenerateNumber([]int{256}, 10)
+	sharing := scheme.NewCRTSharing(10, 3, mod)
+	want := new(bigint.Int).Set(sharing.Secret)
+	secret := sharing.Secret
+	sharing.DropSecret()
+	assert.Nil(t, sharing.Secret)
+	assert.Equal(t, 0, secret.BitLen())
+	assert.Len(t, sharing.Remainder, 10)
+	_, err := sharing.Refresh()
+	assert.ErrorIs(t, err, scheme.ErrNoSecret)
+
+	// The secret comes back from the remainders
+	assert.NoError(t, sharing.RestoreSecret())
+	assert.Equal(t, 0, want.Cmp(sharing.Secret))
+	assert.NoError(t, sharing.Validate())
+	_, err = sharing.Refresh()
+	assert.NoError(t, err)
+
+	sharing.Wipe()
+	assert.ErrorIs(t, sharing.RestoreSecret(), scheme.ErrNoSecret)
+}